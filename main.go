@@ -7,12 +7,16 @@ import (
 	"time"
 
 	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
+	"fiber-ecommerce/notify"
 	"fiber-ecommerce/routes"
+	"fiber-ecommerce/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 func main() {
@@ -27,14 +31,19 @@ func main() {
 		}
 	}()
 
+	// Session store used by the JWT middlewares to reject revoked tokens
+	middleware.InitSessions(db)
+
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		BodyLimit:    50 * 1024 * 1024, // 50MB for file uploads
+		ErrorHandler: utils.ErrorHandler,
 	})
 
 	// Middleware
+	app.Use(requestid.New())
 	app.Use(recover.New())
 	app.Use(logger.New())
 	// Updated CORS middleware configuration
@@ -43,7 +52,7 @@ func main() {
 		AllowMethods:     "GET,POST,PUT,DELETE,PATCH,OPTIONS,HEAD",
 		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,Access-Control-Allow-Origin,Access-Control-Allow-Headers,Access-Control-Allow-Methods,Access-Control-Allow-Credentials,X-Requested-With",
 		AllowCredentials: true,
-		ExposeHeaders:    "Content-Length,Access-Control-Allow-Origin,Access-Control-Allow-Headers,Cache-Control,Content-Language,Content-Type",
+		ExposeHeaders:    "Content-Length,Access-Control-Allow-Origin,Access-Control-Allow-Headers,Cache-Control,Content-Language,Content-Type,X-Request-ID",
 	}))
 
 	// Static files for uploads
@@ -52,12 +61,19 @@ func main() {
 	// API Routes
 	api := app.Group("/api")
 
+	// Outgoing SMS for one-time codes and password resets
+	sms := notify.NewSMSSenderFromEnv()
+	notifier := notify.NewNotifier(sms, notify.NewEmailSenderFromEnv())
+
 	// Authentication routes
 	routes.UserAuthRoutes(api, db)  // User authentication (email/password)
 	routes.AdminAuthRoutes(api, db) // Admin authentication
+	routes.SecurityAuditRoutes(api, db)
+	routes.PhoneOTPRoutes(api, db, sms) // Phone verification and passwordless login
+	routes.PasswordResetRoutes(api, db, notifier)
 
 	// User Management routes (Admin only)
-	// routes.UserManagementRoutes(api, db) // Admin can monitor/manage users
+	routes.UserManagementRoutes(api, db, notifier) // Admin can monitor/manage users
 	routes.AdminDashboardRoutes(api, db)
 
 	// Core models CRUD routes
@@ -70,6 +86,7 @@ func main() {
 	routes.CounterpartyRoutes(api, db)
 	routes.ContractRoutes(api, db)
 	routes.FunnelRoutes(api, db)
+	routes.CRMHistoryRoutes(api, db) // Rebuild denormalized order history
 
 	// Information pages (singleton models)
 	routes.AboutRoutes(api, db)
@@ -103,6 +120,13 @@ func main() {
 	// File upload route
 	routes.FileRoutes(api, db)
 
+	// Trash of soft-deleted documents, purged after TRASH_RETENTION_DAYS
+	routes.TrashRoutes(api, db)
+	routes.StartTrashPurger(db)
+
+	// Audit trail of every create, update and delete
+	routes.AuditLogRoutes(api, db)
+
 	// Root endpoint
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -144,20 +168,17 @@ func main() {
 
 	// 404 handler for debugging
 	app.Use(func(c *fiber.Ctx) error {
-		return c.Status(404).JSON(fiber.Map{
-			"error":   "Route not found",
-			"path":    c.Path(),
-			"method":  c.Method(),
-			"message": "Please check the API documentation for available endpoints",
-			"available_endpoints": fiber.Map{
+		return utils.NotFound("Route not found. Please check the API documentation for available endpoints").
+			With("path", c.Path()).
+			With("method", c.Method()).
+			With("available_endpoints", fiber.Map{
 				"auth":     "/api/auth/*",
 				"admin":    "/api/admin/*",
 				"users":    "/api/users/* (admin only)",
 				"orders":   "/api/orders/*",
 				"products": "/api/products/*",
 				"files":    "/api/files/*",
-			},
-		})
+			})
 	})
 
 	// Start server
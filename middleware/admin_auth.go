@@ -4,30 +4,35 @@ import (
 	"os"
 	"strings"
 
+	"fiber-ecommerce/utils"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func AdminJWTMiddleware() fiber.Handler {
+// AdminJWTMiddleware validates the admin bearer token. When roles are given,
+// the admin's role must be one of them; otherwise any admin is allowed.
+func AdminJWTMiddleware(roles ...string) fiber.Handler {
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		jwtSecret = "your-super-secret-jwt-key-here"
 	}
 
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[role] = true
+	}
+
 	return func(c *fiber.Ctx) error {
 		// Get token from Authorization header
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Authorization header required",
-			})
+			return utils.Unauthorized("Authorization header required")
 		}
 
 		// Check if token starts with "Bearer "
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid authorization format",
-			})
+			return utils.Unauthorized("Invalid authorization format")
 		}
 
 		// Extract token
@@ -43,29 +48,35 @@ func AdminJWTMiddleware() fiber.Handler {
 		})
 
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token",
-			})
+			return utils.Unauthorized("Invalid token")
 		}
 
 		// Extract claims
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 			// Check if token type is admin
 			if tokenType, exists := claims["type"]; !exists || tokenType != "admin" {
-				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-					"error": "Invalid token type",
-				})
+				return utils.Unauthorized("Invalid token type")
+			}
+
+			sessionID, _ := claims["sid"].(string)
+			if !isSessionActive(sessionID, "admin") {
+				return utils.Unauthorized("Session expired or revoked")
+			}
+
+			role, _ := claims["role"].(string)
+			if len(allowed) > 0 && !allowed[role] {
+				return utils.Forbidden("Insufficient permissions")
 			}
 
 			// Store admin info in context
 			c.Locals("admin_id", claims["admin_id"])
 			c.Locals("admin_name", claims["admin_name"])
-			
+			c.Locals("admin_role", role)
+			c.Locals("session_id", sessionID)
+
 			return c.Next()
 		}
 
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid token claims",
-		})
+		return utils.Unauthorized("Invalid token claims")
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"fiber-ecommerce/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var sessionCollection *mongo.Collection
+
+// InitSessions wires the sessions collection used by the JWT middlewares to
+// reject tokens whose session has been revoked or has expired.
+func InitSessions(db *mongo.Client) {
+	sessionCollection = config.GetCollection(db, "sessions")
+
+	_, err := sessionCollection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "refresh_token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "subject_id", Value: 1}, {Key: "subject_type", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create session indexes: %v", err)
+	}
+}
+
+// isSessionActive reports whether the session exists, is not revoked and has
+// not expired. Without an initialized store every session is rejected.
+func isSessionActive(sessionID, subjectType string) bool {
+	if sessionCollection == nil {
+		return false
+	}
+
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return false
+	}
+
+	count, err := sessionCollection.CountDocuments(context.TODO(), bson.M{
+		"_id":          id,
+		"subject_type": subjectType,
+		"revoked_at":   nil,
+		"expires_at":   bson.M{"$gt": time.Now()},
+	}, options.Count().SetLimit(1))
+	return err == nil && count > 0
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"os"
+	"strings"
+
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// UserJWTMiddleware validates the user bearer token and stores the user
+// identity in the request context.
+func UserJWTMiddleware() fiber.Handler {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		jwtSecret = "your-super-secret-jwt-key-here"
+	}
+
+	return func(c *fiber.Ctx) error {
+		// Get token from Authorization header
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return utils.Unauthorized("Authorization header required")
+		}
+
+		// Check if token starts with "Bearer "
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			return utils.Unauthorized("Invalid authorization format")
+		}
+
+		// Extract token
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+		// Parse and validate token
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			// Validate signing method
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid signing method")
+			}
+			return []byte(jwtSecret), nil
+		})
+
+		if err != nil {
+			return utils.Unauthorized("Invalid token")
+		}
+
+		// Extract claims
+		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+			// Check if token type is user
+			if tokenType, exists := claims["type"]; !exists || tokenType != "user" {
+				return utils.Unauthorized("Invalid token type")
+			}
+
+			userID, ok := claims["user_id"].(string)
+			if !ok || userID == "" {
+				return utils.Unauthorized("Invalid token claims")
+			}
+
+			sessionID, _ := claims["sid"].(string)
+			if !isSessionActive(sessionID, "user") {
+				return utils.Unauthorized("Session expired or revoked")
+			}
+
+			// Store user info in context
+			c.Locals("user_id", userID)
+			c.Locals("user_phone", claims["phone"])
+			c.Locals("session_id", sessionID)
+
+			return c.Next()
+		}
+
+		return utils.Unauthorized("Invalid token claims")
+	}
+}
+
+// OptionalUserJWTMiddleware identifies the user on public routes. Requests
+// without a user bearer token pass through anonymously; a user token must be
+// valid, exactly as with UserJWTMiddleware.
+func OptionalUserJWTMiddleware() fiber.Handler {
+	required := UserJWTMiddleware()
+
+	return func(c *fiber.Ctx) error {
+		authHeader := c.Get("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			return c.Next()
+		}
+
+		// Tokens of other types (e.g. from the admin panel) are ignored, not rejected
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(strings.TrimPrefix(authHeader, "Bearer "), claims); err == nil && claims["type"] != "user" {
+			return c.Next()
+		}
+
+		return required(c)
+	}
+}
@@ -8,34 +8,113 @@ import (
 
 // User model for authentication
 type User struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
-	Email     string             `json:"email,omitempty" bson:"email,omitempty"`
-	Phone     string             `json:"phone" bson:"phone"`
-	Password  string             `json:"password" bson:"password"`
-	IsActive  bool               `json:"is_active" bson:"is_active"`
-	LastLogin *time.Time         `json:"last_login,omitempty" bson:"last_login,omitempty"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name          string             `json:"name" bson:"name" validate:"required,max=100"`
+	Email         string             `json:"email,omitempty" bson:"email,omitempty" validate:"email"`
+	Phone         string             `json:"phone" bson:"phone" validate:"required,uz-phone"`
+	Password      string             `json:"password" bson:"password"`
+	IsActive      bool               `json:"is_active" bson:"is_active" validate:"readonly"`
+	PhoneVerified bool               `json:"phone_verified" bson:"phone_verified" validate:"readonly"`
+	LastLogin     *time.Time         `json:"last_login,omitempty" bson:"last_login,omitempty" validate:"readonly"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
 // Updated login request to use phone instead of email
 type UserLoginRequest struct {
-	Phone    string `json:"phone"`
-	Password string `json:"password"`
+	Phone    string `json:"phone" validate:"required,uz-phone"`
+	Password string `json:"password" validate:"required"`
 }
 
 // Updated register request - phone is required, email is optional
 type UserRegisterRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email,omitempty"`
-	Phone    string `json:"phone"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email,omitempty" validate:"email"`
+	Phone    string `json:"phone" validate:"required,uz-phone"`
+	Password string `json:"password" validate:"required"`
 }
 
 type UserAuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	User         User   `json:"user"`
+}
+
+// OTP purposes
+const (
+	OTPPurposeVerify = "verify"
+	OTPPurposeLogin  = "login"
+)
+
+// OTPRequest asks for a one-time code to be sent to a phone number
+type OTPRequest struct {
+	Phone   string `json:"phone" validate:"required,uz-phone"`
+	Purpose string `json:"purpose" validate:"enum=verify|login"`
+}
+
+// OTPVerifyRequest submits a one-time code received by SMS
+type OTPVerifyRequest struct {
+	Phone string `json:"phone" validate:"required"`
+	Code  string `json:"code" validate:"required"`
+}
+
+// PhoneOTP is a pending one-time code. Only the code hash is stored and the
+// document expires automatically through a TTL index on expires_at.
+type PhoneOTP struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Phone     string             `json:"phone" bson:"phone"`
+	Purpose   string             `json:"purpose" bson:"purpose"`
+	CodeHash  string             `json:"-" bson:"code_hash"`
+	Attempts  int                `json:"attempts" bson:"attempts"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// ForgotPasswordRequest starts a password reset. Users are looked up by phone
+// or email, admins by name.
+type ForgotPasswordRequest struct {
+	Phone string `json:"phone,omitempty" validate:"uz-phone"`
+	Email string `json:"email,omitempty" validate:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// ResetPasswordRequest completes a password reset with the token received
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// PasswordReset is a single-use reset token. Only the token hash is stored and
+// the document expires automatically through a TTL index on expires_at.
+type PasswordReset struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SubjectType string             `json:"subject_type" bson:"subject_type"`
+	SubjectID   primitive.ObjectID `json:"subject_id" bson:"subject_id"`
+	TokenHash   string             `json:"-" bson:"token_hash"`
+	UsedAt      *time.Time         `json:"used_at" bson:"used_at"`
+	ExpiresAt   time.Time          `json:"expires_at" bson:"expires_at"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// RefreshTokenRequest exchanges a refresh token for a new token pair
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Session tracks a refresh token issued to an admin or user. Access tokens
+// carry the session ID so revoking the session invalidates them immediately.
+type Session struct {
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SubjectID        primitive.ObjectID `json:"subject_id" bson:"subject_id"`
+	SubjectType      string             `json:"subject_type" bson:"subject_type"`
+	RefreshTokenHash string             `json:"-" bson:"refresh_token_hash"`
+	UserAgent        string             `json:"user_agent" bson:"user_agent"`
+	IP               string             `json:"ip" bson:"ip"`
+	ExpiresAt        time.Time          `json:"expires_at" bson:"expires_at"`
+	RevokedAt        *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at"`
+	LastUsedAt       time.Time          `json:"last_used_at" bson:"last_used_at"`
+	CreatedAt        time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
 // OrderHistoryProduct documents goods associated with a company/client order.
@@ -55,6 +134,7 @@ type OrderHistoryEntry struct {
 	ID          string                `json:"id" bson:"id"`
 	OrderNumber string                `json:"order_number" bson:"order_number"`
 	Price       FlexFloat64           `json:"price" bson:"price"`
+	Currency    string                `json:"currency" bson:"currency"`
 	Status      string                `json:"status" bson:"status"`
 	CreatedAt   time.Time             `json:"created_at" bson:"created_at"`
 	UpdatedAt   time.Time             `json:"updated_at" bson:"updated_at"`
@@ -64,55 +144,62 @@ type OrderHistoryEntry struct {
 // Company model aligns with CRM requirements.
 type Company struct {
 	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
-	Name         string              `json:"name" bson:"name"`
-	OrderCount   int                 `json:"order_count" bson:"order_count"`
-	TotalAmount  FlexFloat64         `json:"total_amount" bson:"total_amount"`
-	Email        string              `json:"email" bson:"email"`
-	Inn          string              `json:"inn" bson:"inn"`
-	Address      string              `json:"address" bson:"address"`
-	Phone        string              `json:"phone" bson:"phone"`
+	Name         string              `json:"name" bson:"name" validate:"required"`
+	OrderCount   int                 `json:"order_count" bson:"order_count" validate:"readonly"`
+	TotalAmount  FlexFloat64         `json:"total_amount" bson:"total_amount" validate:"readonly"`
+	Totals       map[string]float64  `json:"totals" bson:"totals" validate:"readonly"` // total_amount per currency
+	Email        string              `json:"email" bson:"email" validate:"required,email"`
+	Inn          string              `json:"inn" bson:"inn" validate:"required"`
+	Address      string              `json:"address" bson:"address" validate:"required"`
+	Phone        string              `json:"phone" bson:"phone" validate:"required"`
 	Comment      *string             `json:"comment,omitempty" bson:"comment,omitempty"`
 	CreatedAt    time.Time           `json:"created_at" bson:"created_at"`
 	UpdatedAt    time.Time           `json:"updated_at" bson:"updated_at"`
-	OrderHistory []OrderHistoryEntry `json:"order_history" bson:"order_history"`
+	OrderHistory []OrderHistoryEntry `json:"order_history" bson:"order_history" validate:"readonly"`
 }
 
 // Client model (CRM-centric)
 type Client struct {
 	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
-	FirstName    string              `json:"first_name" bson:"first_name"`
-	LastName     string              `json:"last_name" bson:"last_name"`
-	Email        string              `json:"email" bson:"email"`
-	Phone        string              `json:"phone" bson:"phone"`
+	FirstName    string              `json:"first_name" bson:"first_name" validate:"required"`
+	LastName     string              `json:"last_name" bson:"last_name" validate:"required"`
+	OrderCount   int                 `json:"order_count" bson:"order_count" validate:"readonly"`
+	TotalAmount  FlexFloat64         `json:"total_amount" bson:"total_amount" validate:"readonly"`
+	Totals       map[string]float64  `json:"totals" bson:"totals" validate:"readonly"` // total_amount per currency
+	Email        string              `json:"email" bson:"email" validate:"required,email"`
+	Phone        string              `json:"phone" bson:"phone" validate:"required"`
 	CompanyPhone string              `json:"company_phone" bson:"company_phone"`
 	Company      string              `json:"company" bson:"company"`
 	Address      string              `json:"address" bson:"address"`
 	Comment      *string             `json:"comment,omitempty" bson:"comment,omitempty"`
 	CreatedAt    time.Time           `json:"created_at" bson:"created_at"`
 	UpdatedAt    time.Time           `json:"updated_at" bson:"updated_at"`
-	OrderHistory []OrderHistoryEntry `json:"order_history" bson:"order_history"`
+	OrderHistory []OrderHistoryEntry `json:"order_history" bson:"order_history" validate:"readonly"`
 }
 
 // Counterparty model mirrors the client schema.
 type Counterparty struct {
 	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
-	FirstName    string              `json:"first_name" bson:"first_name"`
-	LastName     string              `json:"last_name" bson:"last_name"`
-	Email        string              `json:"email" bson:"email"`
-	Phone        string              `json:"phone" bson:"phone"`
+	FirstName    string              `json:"first_name" bson:"first_name" validate:"required"`
+	LastName     string              `json:"last_name" bson:"last_name" validate:"required"`
+	OrderCount   int                 `json:"order_count" bson:"order_count" validate:"readonly"`
+	TotalAmount  FlexFloat64         `json:"total_amount" bson:"total_amount" validate:"readonly"`
+	Totals       map[string]float64  `json:"totals" bson:"totals" validate:"readonly"` // total_amount per currency
+	Email        string              `json:"email" bson:"email" validate:"required,email"`
+	Phone        string              `json:"phone" bson:"phone" validate:"required"`
 	CompanyPhone string              `json:"company_phone" bson:"company_phone"`
 	Company      string              `json:"company" bson:"company"`
 	Address      string              `json:"address" bson:"address"`
 	Comment      *string             `json:"comment,omitempty" bson:"comment,omitempty"`
 	CreatedAt    time.Time           `json:"created_at" bson:"created_at"`
 	UpdatedAt    time.Time           `json:"updated_at" bson:"updated_at"`
-	OrderHistory []OrderHistoryEntry `json:"order_history" bson:"order_history"`
+	OrderHistory []OrderHistoryEntry `json:"order_history" bson:"order_history" validate:"readonly"`
 }
 
 // TopCategory model (updated)
 type TopCategory struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
+	Name      string             `json:"name" bson:"name" validate:"required"`
 	Image     string             `json:"image" bson:"image"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
@@ -121,58 +208,176 @@ type TopCategory struct {
 // Category model (updated with top_category_name)
 type Category struct {
 	ID              primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
-	Name            string              `json:"name" bson:"name"`
+	Name            string              `json:"name" bson:"name" validate:"required"`
 	Image           string              `json:"image" bson:"image"`
 	TopCategoryID   *primitive.ObjectID `json:"top_category_id" bson:"top_category_id"`
-	TopCategoryName string              `json:"top_category_name,omitempty" bson:"top_category_name,omitempty"`
+	TopCategoryName string              `json:"top_category_name,omitempty" bson:"top_category_name,omitempty" validate:"readonly"`
 	CreatedAt       time.Time           `json:"created_at" bson:"created_at"`
 	UpdatedAt       time.Time           `json:"updated_at" bson:"updated_at"`
 }
 
 // Product model (updated with all required fields)
 type Product struct {
-	ID              primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
-	Name            string              `json:"name" bson:"name"`
-	AdsTitle        string              `json:"ads_title" bson:"ads_title"`
-	Images          []string            `json:"images" bson:"image"`
-	Description     string              `json:"description" bson:"description"`
-	Guarantee       string              `json:"guarantee" bson:"guarantee"`
-	SerialNumber    string              `json:"serial_number" bson:"serial_number"`
-	ShtrixNumber    string              `json:"shtrix_number" bson:"shtrix_number"`
-	Price           FlexFloat64         `json:"price" bson:"price"`
-	Discount        FlexFloat64         `json:"discount" bson:"discount,omitempty"`
-	CategoryID      *primitive.ObjectID `json:"category_id" bson:"category_id"`
-	TopCategoryID   *primitive.ObjectID `json:"top_category_id" bson:"top_category_id"`
-	CategoryName    *string             `json:"category_name" bson:"category_name,omitempty"`
-	TopCategoryName *string             `json:"top_category_name" bson:"top_category_name,omitempty"`
-	Count           int                 `json:"count" bson:"count"`
-	NDC             FlexFloat64         `json:"NDC" bson:"NDC,omitempty"`
-	Tax             FlexFloat64         `json:"tax" bson:"tax"`
-	CreatedAt       time.Time           `json:"created_at" bson:"created_at"`
-	UpdatedAt       time.Time           `json:"updated_at" bson:"updated_at"`
+	ID               primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Name             string              `json:"name" bson:"name"`
+	AdsTitle         string              `json:"ads_title" bson:"ads_title"`
+	Images           []string            `json:"images" bson:"image"`
+	Description      string              `json:"description" bson:"description"`
+	Guarantee        string              `json:"guarantee" bson:"guarantee"`
+	SerialNumber     string              `json:"serial_number" bson:"serial_number"`
+	ShtrixNumber     string              `json:"shtrix_number" bson:"shtrix_number" validate:"required"`
+	Price            FlexFloat64         `json:"price" bson:"price" validate:"required,coerce"`
+	Discount         FlexFloat64         `json:"discount" bson:"discount,omitempty" validate:"coerce"`
+	CategoryID       *primitive.ObjectID `json:"category_id" bson:"category_id"`
+	TopCategoryID    *primitive.ObjectID `json:"top_category_id" bson:"top_category_id"`
+	CategoryName     *string             `json:"category_name" bson:"category_name,omitempty" validate:"readonly"`
+	TopCategoryName  *string             `json:"top_category_name" bson:"top_category_name,omitempty" validate:"readonly"`
+	Count            int                 `json:"count" bson:"count" validate:"coerce,min=0"`
+	Reserved         int                 `json:"reserved" bson:"reserved" validate:"readonly"`
+	ReorderThreshold *int                `json:"reorder_threshold,omitempty" bson:"reorder_threshold,omitempty" validate:"coerce,min=0"`
+	NDC              FlexFloat64         `json:"NDC" bson:"NDC,omitempty" validate:"coerce"`
+	Tax              FlexFloat64         `json:"tax" bson:"tax" validate:"required,coerce"`
+	CreatedAt        time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at" bson:"updated_at"`
+}
+
+// Available returns the stock that is neither shipped nor reserved by confirmed orders.
+func (p Product) Available() int {
+	return p.Count - p.Reserved
+}
+
+// Counter is a named sequence in the counters collection
+type Counter struct {
+	ID  string `json:"id" bson:"_id"`
+	Seq int64  `json:"seq" bson:"seq"`
+}
+
+// Stock movement types
+const (
+	StockMovementReserve    = "reserve"
+	StockMovementRelease    = "release"
+	StockMovementShip       = "ship"
+	StockMovementAdjustment = "adjustment"
+	StockMovementRevert     = "revert"
+)
+
+// StockMovement is one entry of the per-product stock ledger. CountDelta is
+// the change to the on-hand count, ReservedDelta the change to the reserved
+// quantity.
+type StockMovement struct {
+	ID            primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	ProductID     primitive.ObjectID  `json:"product_id" bson:"product_id"`
+	OrderID       *primitive.ObjectID `json:"order_id,omitempty" bson:"order_id,omitempty"`
+	Type          string              `json:"type" bson:"type"`
+	CountDelta    int                 `json:"count_delta" bson:"count_delta"`
+	ReservedDelta int                 `json:"reserved_delta" bson:"reserved_delta"`
+	ActorType     string              `json:"actor_type" bson:"actor_type"`
+	ActorID       string              `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	ActorName     string              `json:"actor_name,omitempty" bson:"actor_name,omitempty"`
+	Note          string              `json:"note,omitempty" bson:"note,omitempty"`
+	CreatedAt     time.Time           `json:"created_at" bson:"created_at"`
 }
 
 // Order model (from schema diagram)
 type Order struct {
 	ID                primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	OrderNumber       string              `json:"order_number,omitempty" bson:"order_number,omitempty" validate:"readonly"`
 	Phone             string              `json:"phone" bson:"phone"`
 	PayType           string              `json:"pay_type" bson:"pay_type"`
-	ProductsWithCount []ProductWithCount  `json:"products" bson:"products"`
+	ProductsWithCount []ProductWithCount  `json:"products" bson:"products" validate:"required"`
 	ClientID          *primitive.ObjectID `json:"client_id" bson:"client_id"`
+	UserID            *primitive.ObjectID `json:"user_id,omitempty" bson:"user_id,omitempty" validate:"readonly"`
+	Status            string              `json:"status" bson:"status" validate:"readonly"`
+	StockReserved     bool                `json:"stock_reserved" bson:"stock_reserved" validate:"readonly"`
+	Subtotal          float64             `json:"subtotal" bson:"subtotal" validate:"readonly"`
+	DiscountTotal     float64             `json:"discount_total" bson:"discount_total" validate:"readonly"`
+	TaxTotal          float64             `json:"tax_total" bson:"tax_total" validate:"readonly"`
+	TotalAmount       float64             `json:"total_amount" bson:"total_amount" validate:"readonly"`
+	StatusHistory     []OrderStatusChange `json:"status_history" bson:"status_history" validate:"readonly"`
 	CreatedAt         time.Time           `json:"created_at" bson:"created_at"`
 	UpdatedAt         time.Time           `json:"updated_at" bson:"updated_at"`
 }
 
+// CurrentStatus returns the order status, treating orders created before
+// statuses existed as pending.
+func (o Order) CurrentStatus() string {
+	if o.Status == "" {
+		return OrderStatusPending
+	}
+	return o.Status
+}
+
+// Order statuses
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusConfirmed = "confirmed"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+)
+
+// orderStatusTransitions lists the statuses each status may move to. Orders
+// can be cancelled until they ship; delivered and cancelled are final.
+var orderStatusTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed: {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:   {OrderStatusDelivered},
+}
+
+// CanTransitionOrderStatus reports whether an order may move from one status to another.
+func CanTransitionOrderStatus(from, to string) bool {
+	for _, next := range orderStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOrderStatuses returns the statuses an order in the given status may move to.
+func NextOrderStatuses(from string) []string {
+	return append([]string{}, orderStatusTransitions[from]...)
+}
+
+// OrderStatusChange is one entry of an order's status history
+type OrderStatusChange struct {
+	From      string    `json:"from,omitempty" bson:"from,omitempty"`
+	To        string    `json:"to" bson:"to"`
+	ActorType string    `json:"actor_type" bson:"actor_type"` // admin, user or customer
+	ActorID   string    `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	ActorName string    `json:"actor_name,omitempty" bson:"actor_name,omitempty"`
+	Note      string    `json:"note,omitempty" bson:"note,omitempty"`
+	ChangedAt time.Time `json:"changed_at" bson:"changed_at"`
+}
+
+// OrderStatusRequest is the body of PATCH /orders/:id/status
+type OrderStatusRequest struct {
+	Status string `json:"status" validate:"required,enum=pending|confirmed|shipped|delivered|cancelled"`
+	Note   string `json:"note,omitempty"`
+}
+
+// ProductWithCount is one order line. Clients send product_id and count; the
+// remaining fields are a server-side snapshot of the product taken when the
+// order is placed, so later catalog changes do not alter the order.
 type ProductWithCount struct {
-	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
-	Count     int                `json:"count" bson:"count"`
+	ProductID    primitive.ObjectID `json:"product_id" bson:"product_id" validate:"required"`
+	Count        int                `json:"count" bson:"count" validate:"min=1"`
+	Name         string             `json:"name,omitempty" bson:"name,omitempty" validate:"readonly"`
+	UnitPrice    float64            `json:"unit_price" bson:"unit_price" validate:"readonly"`
+	UnitDiscount float64            `json:"unit_discount" bson:"unit_discount" validate:"readonly"`
+	UnitNDC      float64            `json:"unit_ndc" bson:"unit_ndc" validate:"readonly"`
+	UnitTax      float64            `json:"unit_tax" bson:"unit_tax" validate:"readonly"`
+	LineSubtotal float64            `json:"line_subtotal" bson:"line_subtotal" validate:"readonly"`
+	LineDiscount float64            `json:"line_discount" bson:"line_discount" validate:"readonly"`
+	LineTax      float64            `json:"line_tax" bson:"line_tax" validate:"readonly"`
+	LineTotal    float64            `json:"line_total" bson:"line_total" validate:"readonly"`
 }
 
 // ContractProduct details individual goods in a contract agreement.
 type ContractProduct struct {
-	ProductID    primitive.ObjectID `json:"product_id" bson:"product_id"`
+	ProductID    primitive.ObjectID `json:"product_id" bson:"product_id" validate:"required"`
 	Price        FlexFloat64        `json:"price" bson:"price"`
-	Quantity     int                `json:"quantity" bson:"quantity"`
+	Quantity     int                `json:"quantity" bson:"quantity" validate:"min=1"`
 	Discount     FlexFloat64        `json:"discount" bson:"discount,omitempty"`
 	SerialNumber string             `json:"serial_number" bson:"serial_number"`
 	ShtrixNumber string             `json:"shtrix_number,omitempty" bson:"shtrix_number,omitempty"`
@@ -181,18 +386,19 @@ type ContractProduct struct {
 // Contract represents agreements among clients, counterparties, and companies.
 type Contract struct {
 	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	ClientID         primitive.ObjectID `json:"client_id" bson:"client_id"`
+	ContractNumber   string             `json:"contract_number,omitempty" bson:"contract_number,omitempty" validate:"readonly"`
+	ClientID         primitive.ObjectID `json:"client_id" bson:"client_id" validate:"required"`
 	ClientName       *string            `json:"client_name,omitempty" bson:"client_name,omitempty"`
-	CounterpartyID   primitive.ObjectID `json:"counterparty_id" bson:"counterparty_id"`
+	CounterpartyID   primitive.ObjectID `json:"counterparty_id" bson:"counterparty_id" validate:"required"`
 	CounterpartyName *string            `json:"counterparty_name,omitempty" bson:"counterparty_name,omitempty"`
-	CompanyID        primitive.ObjectID `json:"company_id" bson:"company_id"`
+	CompanyID        primitive.ObjectID `json:"company_id" bson:"company_id" validate:"required"`
 	CompanyName      *string            `json:"company_name,omitempty" bson:"company_name,omitempty"`
 	FunnelID         primitive.ObjectID `json:"funnel_id" bson:"funnel_id"`
 	Guarantee        string             `json:"guarantee" bson:"guarantee"`
 	Comment          string             `json:"comment" bson:"comment"`
-	DealDate         time.Time          `json:"deal_date" bson:"deal_date"`
+	DealDate         time.Time          `json:"deal_date" bson:"deal_date" validate:"required"`
 	ContractAmount   FlexFloat64        `json:"contract_amount" bson:"contract_amount"`
-	ContractCurrency string             `json:"contract_currency" bson:"contract_currency"`
+	ContractCurrency string             `json:"contract_currency" bson:"contract_currency" validate:"required,currency"`
 	PayCard          FlexFloat64        `json:"pay_card" bson:"pay_card"`
 	PayCash          FlexFloat64        `json:"pay_cash" bson:"pay_cash"`
 	CreatedAt        time.Time          `json:"created_at" bson:"created_at"`
@@ -218,7 +424,7 @@ type About struct {
 // Vendor model (from schema diagram)
 type Vendor struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Image     string             `json:"image" bson:"image"`
+	Image     string             `json:"image" bson:"image" validate:"required"`
 	URL       string             `json:"url" bson:"url"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
@@ -227,7 +433,7 @@ type Vendor struct {
 // Project model (from schema diagram)
 type Project struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Image     string             `json:"image" bson:"image"`
+	Image     string             `json:"image" bson:"image" validate:"required"`
 	URL       string             `json:"url" bson:"url"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
@@ -249,17 +455,17 @@ type Links struct {
 // Reviews model
 type Reviews struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
+	Name      string             `json:"name" bson:"name" validate:"required"`
 	Phone     string             `json:"phone" bson:"phone"`
-	Email     string             `json:"email" bson:"email"`
-	Message   string             `json:"message" bson:"message"`
+	Email     string             `json:"email" bson:"email" validate:"email"`
+	Message   string             `json:"message" bson:"message" validate:"required"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 }
 
 // Sertificate model
 type Sertificate struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
+	Name      string             `json:"name" bson:"name" validate:"required"`
 	Image     string             `json:"image" bson:"image"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
@@ -268,7 +474,7 @@ type Sertificate struct {
 // License model
 type License struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
+	Name      string             `json:"name" bson:"name" validate:"required"`
 	Image     string             `json:"image" bson:"image"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
@@ -277,7 +483,7 @@ type License struct {
 // News model
 type News struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
+	Name      string             `json:"name" bson:"name" validate:"required"`
 	Image     string             `json:"image" bson:"image"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
@@ -286,33 +492,136 @@ type News struct {
 // Partner model
 type Partner struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
+	Name      string             `json:"name" bson:"name" validate:"required"`
 	Image     string             `json:"image" bson:"image"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
+// Login attempt outcomes recorded in the security audit log
+const (
+	LoginReasonSuccess            = "success"
+	LoginReasonUnknownAccount     = "unknown_account"
+	LoginReasonInvalidPassword    = "invalid_password"
+	LoginReasonAccountDeactivated = "account_deactivated"
+	LoginReasonLockedOut          = "locked_out"
+	LoginReasonInvalidOTP         = "invalid_otp"
+)
+
+// LoginThrottle counts recent failed logins for an account or an IP address.
+// Documents expire automatically through a TTL index on expires_at.
+type LoginThrottle struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Key           string             `json:"key" bson:"key"`
+	Scope         string             `json:"scope" bson:"scope"`
+	SubjectType   string             `json:"subject_type,omitempty" bson:"subject_type,omitempty"`
+	Identifier    string             `json:"identifier" bson:"identifier"`
+	Failures      int                `json:"failures" bson:"failures"`
+	LockedUntil   *time.Time         `json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+	LastFailureAt time.Time          `json:"last_failure_at" bson:"last_failure_at"`
+	ExpiresAt     time.Time          `json:"expires_at" bson:"expires_at"`
+}
+
+// LoginAttempt is a security audit record of a single admin or user login attempt.
+type LoginAttempt struct {
+	ID          primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	SubjectType string              `json:"subject_type" bson:"subject_type"`
+	Identifier  string              `json:"identifier" bson:"identifier"`
+	SubjectID   *primitive.ObjectID `json:"subject_id,omitempty" bson:"subject_id,omitempty"`
+	IP          string              `json:"ip" bson:"ip"`
+	UserAgent   string              `json:"user_agent" bson:"user_agent"`
+	Success     bool                `json:"success" bson:"success"`
+	Reason      string              `json:"reason" bson:"reason"`
+	CreatedAt   time.Time           `json:"created_at" bson:"created_at"`
+}
+
+// Audit actions
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+)
+
+// AuditChange is the value of one field before and after a change.
+type AuditChange struct {
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
+
+// AuditEvent records who created, changed or deleted a document and which
+// fields changed.
+type AuditEvent struct {
+	ID         primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	Action     string                 `json:"action" bson:"action"`
+	Collection string                 `json:"collection" bson:"collection"`
+	DocumentID primitive.ObjectID     `json:"document_id" bson:"document_id"`
+	ActorType  string                 `json:"actor_type" bson:"actor_type"`
+	ActorID    string                 `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	ActorName  string                 `json:"actor_name,omitempty" bson:"actor_name,omitempty"`
+	IP         string                 `json:"ip" bson:"ip"`
+	Changes    map[string]AuditChange `json:"changes" bson:"changes"`
+	CreatedAt  time.Time              `json:"created_at" bson:"created_at"`
+}
+
+// Admin roles
+const (
+	RoleSuperAdmin    = "superadmin"
+	RoleManager       = "manager"
+	RoleContentEditor = "content_editor"
+	RoleSales         = "sales"
+)
+
+// IsValidAdminRole reports whether role is one of the known admin roles.
+func IsValidAdminRole(role string) bool {
+	switch role {
+	case RoleSuperAdmin, RoleManager, RoleContentEditor, RoleSales:
+		return true
+	default:
+		return false
+	}
+}
+
 // Admin model
 type Admin struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
-	Password  string             `json:"password" bson:"password"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name          string             `json:"name" bson:"name" validate:"required"`
+	Password      string             `json:"password" bson:"password" validate:"required"`
+	Role          string             `json:"role" bson:"role" validate:"enum=superadmin|manager|content_editor|sales"`
+	Email         string             `json:"email,omitempty" bson:"email,omitempty" validate:"email"`
+	Phone         string             `json:"phone,omitempty" bson:"phone,omitempty" validate:"uz-phone"`
+	DeactivatedAt *time.Time         `json:"deactivated_at,omitempty" bson:"deactivated_at,omitempty" validate:"readonly"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// IsActive reports whether the admin account has not been deactivated.
+func (a Admin) IsActive() bool {
+	return a.DeactivatedAt == nil
+}
+
+// EffectiveRole returns the admin role, treating admins created before roles
+// existed as superadmins.
+func (a Admin) EffectiveRole() string {
+	if a.Role == "" {
+		return RoleSuperAdmin
+	}
+	return a.Role
 }
 
 // Currency model
 type Currency struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Sum       string             `json:"sum" bson:"sum"`
-	CreatedAt time.Time          `json:"created_at" bsom:"created_at"`
+	Sum       string             `json:"sum" bson:"sum" validate:"required,coerce"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
 // Banner model
 type Banner struct {
 	ID            primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
-	Image         string              `json:"image" bson:"image"`
+	Image         string              `json:"image" bson:"image" validate:"required"`
 	Title         string              `json:"title" bson:"title"`
 	Description   string              `json:"description" bson:"description"`
 	TopCategoryID *primitive.ObjectID `json:"top_category_id" bson:"top_category_id"`
@@ -326,7 +635,7 @@ type Banner struct {
 type SelectReview struct {
 	ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
 	ReviewID  *primitive.ObjectID `json:"review_id" bson:"review_id"`
-	Name      string              `json:"name" bson:"name"`
+	Name      string              `json:"name" bson:"name" validate:"required"`
 	Phone     string              `json:"phone" bson:"phone"`
 	Email     string              `json:"email" bson:"email"`
 	Message   string              `json:"message" bson:"message"`
@@ -336,7 +645,7 @@ type SelectReview struct {
 // Background model
 type Background struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
+	Name      string             `json:"name" bson:"name" validate:"required"`
 	Image     string             `json:"image" bson:"image"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
@@ -345,7 +654,7 @@ type Background struct {
 // Contacts model
 type Contacts struct {
 	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	CompanyName    string             `json:"company_name" bson:"company_name"`
+	CompanyName    string             `json:"company_name" bson:"company_name" validate:"required"`
 	Phone1         string             `json:"phone1" bson:"phone1"`
 	Phone2         string             `json:"phone2" bson:"phone2"`
 	WorkHours      string             `json:"work_hours" bson:"work_hours"`
@@ -365,7 +674,7 @@ type Contacts struct {
 // BannerSort model
 type BannerSort struct {
 	ID            primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
-	UniqueID      *int                `json:"unique_id" bson:"unique_id"`
+	UniqueID      *int                `json:"unique_id" bson:"unique_id" validate:"coerce"`
 	BannerID      *primitive.ObjectID `json:"banner_id" bson:"banner_id"`
 	Image         string              `json:"image" bson:"image"`
 	TopCategoryID *primitive.ObjectID `json:"top_category_id" bson:"top_category_id"`
@@ -379,8 +688,8 @@ type BannerSort struct {
 type TopCategorySort struct {
 	ID            primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
 	Name          string              `json:"name" bson:"name"`
-	TopCategoryID *primitive.ObjectID `json:"top_category_id" bson:"top_category_id"`
-	UniqueID      *int                `json:"unique_id" bson:"unique_id"`
+	TopCategoryID *primitive.ObjectID `json:"top_category_id" bson:"top_category_id" validate:"required"`
+	UniqueID      *int                `json:"unique_id" bson:"unique_id" validate:"coerce"`
 	CreatedAt     time.Time           `json:"created_at" bson:"created_at"`
 	UpdatedAt     time.Time           `json:"updated_at" bson:"updated_at"`
 }
@@ -388,9 +697,9 @@ type TopCategorySort struct {
 // CategorySort model
 type CategorySort struct {
 	ID                primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	UniqueID          int                `json:"unique_id" bson:"unique_id"`
-	CategoryID        primitive.ObjectID `json:"category_id" bson:"category_id"`
-	TopCategorySortID int                `json:"top_category_sort_id" bson:"top_category_sort_id"`
+	UniqueID          int                `json:"unique_id" bson:"unique_id" validate:"coerce"`
+	CategoryID        primitive.ObjectID `json:"category_id" bson:"category_id" validate:"required"`
+	TopCategorySortID int                `json:"top_category_sort_id" bson:"top_category_sort_id" validate:"coerce"`
 	Name              string             `json:"name" bson:"name"`
 	CreatedAt         time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt         time.Time          `json:"updated_at" bson:"updated_at"`
@@ -407,7 +716,7 @@ type FileUploadResponse struct {
 type Discount struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	Title     string             `json:"title" bson:"title"`
-	ProductID string             `json:"product_id" bson:"product_id"`
+	ProductID string             `json:"product_id" bson:"product_id" validate:"objectid"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
 }
@@ -415,7 +724,7 @@ type Discount struct {
 // Vendors_about model
 type Vendors_about struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name        string             `json:"name" bson:"name"`
+	Name        string             `json:"name" bson:"name" validate:"required"`
 	Description string             `json:"description" bson:"description"`
 	Image       string             `json:"image" bson:"image"`
 	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
@@ -425,7 +734,7 @@ type Vendors_about struct {
 // Official_partner model (singleton)
 type Official_partner struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name        string             `json:"name" bson:"name"`
+	Name        string             `json:"name" bson:"name" validate:"required"`
 	Image       string             `json:"image" bson:"image"`
 	Description string             `json:"description" bson:"description"`
 	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
@@ -435,8 +744,8 @@ type Official_partner struct {
 // Experiments model
 type Experiments struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Count       string             `json:"count" bson:"count"`
-	Title       string             `json:"title" bson:"title"`
+	Count       string             `json:"count" bson:"count" validate:"coerce"`
+	Title       string             `json:"title" bson:"title" validate:"required"`
 	Description string             `json:"description" bson:"description"`
 	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
@@ -445,8 +754,8 @@ type Experiments struct {
 // Company_stats model
 type Company_stats struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Count       string             `json:"count" bson:"count"`
-	Title       string             `json:"title" bson:"title"`
+	Count       string             `json:"count" bson:"count" validate:"coerce"`
+	Title       string             `json:"title" bson:"title" validate:"required"`
 	Description string             `json:"description" bson:"description"`
 	Image       string             `json:"image" bson:"image"`
 	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
@@ -456,10 +765,10 @@ type Company_stats struct {
 // Funnel represents CRM funnel stages.
 type Funnel struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
-	Color     string             `json:"color" bson:"color"`
+	Name      string             `json:"name" bson:"name" validate:"required"`
+	Color     string             `json:"color" bson:"color" validate:"required"`
 	Comment   string             `json:"comment" bson:"comment"`
-	Order     int                `json:"order" bson:"order"`
+	Order     int                `json:"order" bson:"order" validate:"required,coerce"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
 }
@@ -12,6 +12,12 @@ db.createCollection('licenses');
 db.createCollection('news');
 db.createCollection('partners');
 db.createCollection('admins');
+db.createCollection('sessions');
+db.createCollection('login_attempts');
+db.createCollection('audit_log');
+db.createCollection('login_throttles');
+db.createCollection('phone_otps');
+db.createCollection('password_resets');
 db.createCollection('currencies');
 db.createCollection('banners');
 db.createCollection('select_reviews');
@@ -24,6 +30,8 @@ db.createCollection('category_sorts');
 // Create collections for new models from schema diagram
 db.createCollection('clients');
 db.createCollection('orders');
+db.createCollection('stock_movements');
+db.createCollection('counters');
 db.createCollection('about');
 db.createCollection('vendors');
 db.createCollection('projects');
@@ -35,6 +43,31 @@ db.createCollection('links');
 db.users.createIndex({ "email": 1 }, { unique: true });
 db.users.createIndex({ "phone": 1 }, { unique: true });
 
+// Session indexes (expired sessions are removed automatically)
+db.sessions.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+db.sessions.createIndex({ "refresh_token_hash": 1 }, { unique: true });
+db.sessions.createIndex({ "subject_id": 1, "subject_type": 1 });
+
+// Login audit indexes
+db.login_attempts.createIndex({ "created_at": -1 });
+db.login_attempts.createIndex({ "subject_type": 1, "identifier": 1, "created_at": -1 });
+db.audit_log.createIndex({ "collection": 1, "document_id": 1, "created_at": -1 });
+db.audit_log.createIndex({ "actor_id": 1, "created_at": -1 });
+db.audit_log.createIndex({ "created_at": -1 });
+
+// Login throttle indexes (counters expire automatically)
+db.login_throttles.createIndex({ "key": 1 }, { unique: true });
+db.login_throttles.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
+// Phone OTP indexes (codes expire automatically)
+db.phone_otps.createIndex({ "phone": 1, "purpose": 1 }, { unique: true });
+db.phone_otps.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
+// Password reset indexes (tokens expire automatically)
+db.password_resets.createIndex({ "token_hash": 1 }, { unique: true });
+db.password_resets.createIndex({ "subject_id": 1, "subject_type": 1 });
+db.password_resets.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
 // Client indexes
 db.clients.createIndex({ "email": 1 });
 db.clients.createIndex({ "phone": 1 });
@@ -47,6 +80,15 @@ db.categories.createIndex({ "top_category_id": 1 });
 db.products.createIndex({ "category_id": 1 });
 db.orders.createIndex({ "client_id": 1 });
 db.orders.createIndex({ "created_at": -1 });
+db.orders.createIndex({ "status": 1, "created_at": -1 });
+db.orders.createIndex({ "user_id": 1, "created_at": -1 });
+db.orders.createIndex({ "order_number": 1 }, { unique: true, sparse: true });
+db.contracts.createIndex({ "contract_number": 1 }, { unique: true, sparse: true });
+db.contracts.createIndex({ "company_id": 1 });
+db.contracts.createIndex({ "client_id": 1 });
+db.contracts.createIndex({ "counterparty_id": 1 });
+db.stock_movements.createIndex({ "product_id": 1, "created_at": -1 });
+db.stock_movements.createIndex({ "order_id": 1 });
 
 // Banner indexes
 db.banners.createIndex({ "top_category_id": 1 });
@@ -80,6 +122,7 @@ db.admins.deleteMany({});
 db.admins.insertOne({
     name: "admin",
     password: "$2a$10$HLjC0Amd/oTcHvQdhwzyguApEnT2n9XThdJXW.Ib1cBZveRAUe6T2", // bcrypt hash for "123"
+    role: "superadmin",
     created_at: new Date(),
     updated_at: new Date()
 });
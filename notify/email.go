@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmailSender delivers plain-text emails. Real providers implement this
+// interface; the console and file senders are meant for local development.
+type EmailSender interface {
+	SendEmail(to, subject, body string) error
+}
+
+// ConsoleEmailSender writes emails to the application log instead of sending them.
+type ConsoleEmailSender struct{}
+
+// SendEmail logs the email.
+func (ConsoleEmailSender) SendEmail(to, subject, body string) error {
+	log.Printf("📧 Email to %s [%s]: %s", to, subject, body)
+	return nil
+}
+
+// FileEmailSender appends emails to a local file instead of sending them.
+type FileEmailSender struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// SendEmail appends the email to the configured file.
+func (s *FileEmailSender) SendEmail(to, subject, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	body = strings.ReplaceAll(body, "\n", " ")
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\t%s\n", time.Now().Format(time.RFC3339), to, subject, body)
+	return err
+}
+
+// NewEmailSenderFromEnv picks the sender configured by EMAIL_PROVIDER
+// ("console" or "file"). The file sender writes to EMAIL_LOG_FILE (default
+// "email.log").
+func NewEmailSenderFromEnv() EmailSender {
+	switch os.Getenv("EMAIL_PROVIDER") {
+	case "file":
+		path := os.Getenv("EMAIL_LOG_FILE")
+		if path == "" {
+			path = "email.log"
+		}
+		return &FileEmailSender{Path: path}
+	default:
+		return ConsoleEmailSender{}
+	}
+}
@@ -0,0 +1,41 @@
+package notify
+
+import "errors"
+
+// ErrNoChannel is returned when a recipient has no contact the notifier can use.
+var ErrNoChannel = errors.New("recipient has no phone or email")
+
+// Recipient holds the contacts a notification may be delivered to.
+type Recipient struct {
+	Phone string
+	Email string
+}
+
+// Notifier delivers short account messages such as password reset tokens
+// through whatever channel reaches the recipient.
+type Notifier interface {
+	Notify(to Recipient, subject, message string) error
+}
+
+// ChannelNotifier sends by SMS when the recipient has a phone number and
+// falls back to email otherwise. Either sender may be nil to disable it.
+type ChannelNotifier struct {
+	SMS   SMSSender
+	Email EmailSender
+}
+
+// NewNotifier returns a ChannelNotifier using the given senders.
+func NewNotifier(sms SMSSender, email EmailSender) *ChannelNotifier {
+	return &ChannelNotifier{SMS: sms, Email: email}
+}
+
+// Notify delivers the message through the first usable channel.
+func (n *ChannelNotifier) Notify(to Recipient, subject, message string) error {
+	if to.Phone != "" && n.SMS != nil {
+		return n.SMS.SendSMS(to.Phone, message)
+	}
+	if to.Email != "" && n.Email != nil {
+		return n.Email.SendEmail(to.Email, subject, message)
+	}
+	return ErrNoChannel
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender delivers text messages to phone numbers. Real providers implement
+// this interface; the console and file senders are meant for local development.
+type SMSSender interface {
+	SendSMS(phone, message string) error
+}
+
+// ConsoleSMSSender writes messages to the application log instead of sending them.
+type ConsoleSMSSender struct{}
+
+// SendSMS logs the message.
+func (ConsoleSMSSender) SendSMS(phone, message string) error {
+	log.Printf("📱 SMS to %s: %s", phone, message)
+	return nil
+}
+
+// FileSMSSender appends messages to a local file instead of sending them.
+type FileSMSSender struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// SendSMS appends the message to the configured file.
+func (s *FileSMSSender) SendSMS(phone, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), phone, message)
+	return err
+}
+
+// NewSMSSenderFromEnv picks the sender configured by SMS_PROVIDER ("console"
+// or "file"). The file sender writes to SMS_LOG_FILE (default "sms.log").
+func NewSMSSenderFromEnv() SMSSender {
+	switch os.Getenv("SMS_PROVIDER") {
+	case "file":
+		path := os.Getenv("SMS_LOG_FILE")
+		if path == "" {
+			path = "sms.log"
+		}
+		return &FileSMSSender{Path: path}
+	default:
+		return ConsoleSMSSender{}
+	}
+}
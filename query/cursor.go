@@ -0,0 +1,241 @@
+package query
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Lists are paged either with page/limit or with cursors. A cursor is the
+// (created_at, _id) of the last document of a page, so ?after= seeks straight
+// to the next page instead of skipping over every document before it. Cursors
+// only follow the created_at order (the default, or sort=created_at).
+
+// cursor is the position of a document in created_at order.
+type cursor struct {
+	CreatedAt time.Time          `json:"t"`
+	ID        primitive.ObjectID `json:"id"`
+}
+
+func (c cursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// parsePaging reads page, limit, after and total. It runs after sort= is
+// parsed, as cursors depend on the order.
+func (l *List) parsePaging(c *fiber.Ctx) error {
+	l.Page, l.Limit, l.Skip = utils.ParsePaginationParams(c)
+	l.WithTotal = true
+
+	direction := l.direction()
+	if direction != 0 {
+		// _id breaks ties between documents created in the same millisecond
+		l.Sort = bson.D{{Key: "created_at", Value: direction}, {Key: "_id", Value: direction}}
+	}
+
+	if after := c.Query("after"); after != "" {
+		if direction == 0 {
+			return invalid("after", "Cursors only work in created_at order; drop sort or use sort=created_at or sort=-created_at")
+		}
+		position, err := decodeCursor(after)
+		if err != nil {
+			return invalid("after", "Malformed cursor")
+		}
+		l.after = position
+		l.Skip = 0
+		l.WithTotal = false
+	}
+
+	if total := c.Query("total"); total != "" {
+		switch total {
+		case "true", "1":
+			l.WithTotal = true
+		case "false", "0":
+			l.WithTotal = false
+		default:
+			return invalid("total", "Use true or false")
+		}
+	}
+	return nil
+}
+
+// direction returns 1 or -1 when the list is sorted by created_at (and
+// optionally _id the same way), and 0 for any other order.
+func (l *List) direction() int {
+	if len(l.Sort) == 0 || l.Sort[0].Key != "created_at" {
+		return 0
+	}
+	direction, _ := l.Sort[0].Value.(int)
+	switch len(l.Sort) {
+	case 1:
+		return direction
+	case 2:
+		if l.Sort[1].Key == "_id" && l.Sort[1].Value == direction {
+			return direction
+		}
+	}
+	return 0
+}
+
+// Seek restricts filter to the documents after the cursor. Totals are
+// counted on the filter without it.
+func (l *List) Seek(filter bson.M) bson.M {
+	if l.after == nil {
+		return filter
+	}
+	op := "$lt"
+	if l.direction() == 1 {
+		op = "$gt"
+	}
+	return bson.M{"$and": []bson.M{filter, {"$or": []bson.M{
+		{"created_at": bson.M{op: l.after.CreatedAt}},
+		{"created_at": l.after.CreatedAt, "_id": bson.M{op: l.after.ID}},
+	}}}}
+}
+
+// FindOptions returns the sort, skip and limit of the page. One document more
+// than the limit is fetched to tell whether another page follows; Page trims
+// it.
+func (l *List) FindOptions() *options.FindOptions {
+	return options.Find().SetSkip(int64(l.Skip)).SetLimit(int64(l.Limit + 1)).SetSort(l.Sort)
+}
+
+// Page trims the extra document fetched by FindOptions and returns the cursor
+// of the next page: empty on the last page, or when the list is not in
+// created_at order.
+func Page[T any](l *List, items []T) ([]T, string) {
+	if len(items) <= l.Limit {
+		return items, ""
+	}
+	items = items[:l.Limit]
+	l.more = true
+	if l.direction() == 0 {
+		return items, ""
+	}
+	position, ok := positionOf(reflect.ValueOf(items[len(items)-1]))
+	if !ok {
+		return items, ""
+	}
+	return items, position.encode()
+}
+
+// positionOf reads created_at and _id from a model struct or a bson.M.
+func positionOf(v reflect.Value) (cursor, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	var createdAt, id interface{}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return cursor{}, false
+		}
+		if value := v.MapIndex(reflect.ValueOf("created_at")); value.IsValid() {
+			createdAt = value.Interface()
+		}
+		if value := v.MapIndex(reflect.ValueOf("_id")); value.IsValid() {
+			id = value.Interface()
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			switch tagName(v.Type().Field(i).Tag.Get("bson")) {
+			case "created_at":
+				createdAt = v.Field(i).Interface()
+			case "_id":
+				id = v.Field(i).Interface()
+			}
+		}
+	}
+
+	var position cursor
+	switch t := createdAt.(type) {
+	case time.Time:
+		position.CreatedAt = t
+	case primitive.DateTime:
+		position.CreatedAt = t.Time()
+	default:
+		return cursor{}, false
+	}
+	objectID, ok := id.(primitive.ObjectID)
+	if !ok {
+		return cursor{}, false
+	}
+	position.ID = objectID
+	return position, true
+}
+
+// Response is the body of a list: data, limit, next_cursor, the page in
+// page/limit mode, and the total of documents matching filter unless counting
+// is turned off.
+func (l *List) Response(data interface{}, next string, collection *mongo.Collection, filter bson.M) fiber.Map {
+	body := fiber.Map{
+		"data":        data,
+		"limit":       l.Limit,
+		"next_cursor": nextCursor(next),
+	}
+	if l.after == nil {
+		body["page"] = l.Page
+	}
+	if l.WithTotal {
+		total, _ := collection.CountDocuments(context.TODO(), filter)
+		body["total"] = total
+	}
+	return body
+}
+
+// PaginationResponse is Response for endpoints that answer with a pagination
+// object (see utils.PaginationResponse).
+func (l *List) PaginationResponse(data interface{}, next string, collection *mongo.Collection, filter bson.M) fiber.Map {
+	var body fiber.Map
+	if l.WithTotal && l.after == nil {
+		total, _ := collection.CountDocuments(context.TODO(), filter)
+		body = utils.PaginationResponse(data, total, l.Page, l.Limit)
+	} else {
+		pagination := fiber.Map{
+			"per_page": l.Limit,
+			"has_next": l.more,
+		}
+		if l.after == nil {
+			pagination["current_page"] = l.Page
+			pagination["has_prev"] = l.Page > 1
+		}
+		if l.WithTotal {
+			total, _ := collection.CountDocuments(context.TODO(), filter)
+			pagination["total"] = total
+		}
+		body = fiber.Map{"data": data, "pagination": pagination}
+	}
+	body["pagination"].(fiber.Map)["next_cursor"] = nextCursor(next)
+	return body
+}
+
+func nextCursor(next string) interface{} {
+	if next == "" {
+		return nil
+	}
+	return next
+}
@@ -0,0 +1,85 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		value   string
+		want    *cursor
+		wantErr bool
+	}{
+		{"round trip", cursor{CreatedAt: at, ID: oid1}.encode(), &cursor{CreatedAt: at, ID: oid1}, false},
+		{"empty", "", nil, true},
+		{"not base64", "***", nil, true},
+		{"padded base64", "eyJ0IjoiIn0=", nil, true},
+		{"not JSON", "bm90IGpzb24", nil, true},
+		{"bad ObjectID", "eyJ0IjoiMjAyNC0wMy0wMVQxMjozMDowMFoiLCJpZCI6Inp6In0", nil, true},
+		{"bad time", "eyJ0IjoieWVzdGVyZGF5In0", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeCursor(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				assertEqual(t, "cursor", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeek(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	after := &cursor{CreatedAt: at, ID: oid1}
+	seek := func(op string) bson.M {
+		return bson.M{"$and": []bson.M{{"status": "new"}, {"$or": []bson.M{
+			{"created_at": bson.M{op: at}},
+			{"created_at": at, "_id": bson.M{op: oid1}},
+		}}}}
+	}
+	tests := []struct {
+		name string
+		list *List
+		want bson.M
+	}{
+		{"without a cursor", &List{Sort: newest}, bson.M{"status": "new"}},
+		{"newest first", &List{Sort: newest, after: after}, seek("$lt")},
+		{"oldest first", &List{Sort: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}, after: after}, seek("$gt")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertEqual(t, "filter", tt.list.Seek(bson.M{"status": "new"}), tt.want)
+		})
+	}
+}
+
+func TestPage(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	items := []item{{ID: oid1, CreatedAt: at.Add(time.Minute)}, {ID: oid2, CreatedAt: at}, {ID: oid1}}
+
+	l := &List{Sort: newest, Limit: 2}
+	page, next := Page(l, items)
+	if len(page) != 2 || !l.more {
+		t.Fatalf("page has %d items, more = %v", len(page), l.more)
+	}
+	got, err := decodeCursor(next)
+	if err != nil {
+		t.Fatalf("next cursor %q: %v", next, err)
+	}
+	assertEqual(t, "next", got, &cursor{CreatedAt: at, ID: oid2})
+
+	if _, next := Page(&List{Sort: newest, Limit: 3}, items); next != "" {
+		t.Errorf("last page has next cursor %q", next)
+	}
+	if _, next := Page(&List{Sort: bson.D{{Key: "price", Value: 1}}, Limit: 2}, items); next != "" {
+		t.Errorf("price order has next cursor %q", next)
+	}
+}
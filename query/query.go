@@ -0,0 +1,430 @@
+// Package query parses the query language shared by the list endpoints:
+//
+//	sort=-price,name              sort by price descending, then by name
+//	filter[price][gte]=100        compare a field: eq, ne, gt, gte, lt, lte, like
+//	filter[status]=shipped        shorthand for filter[status][eq]=shipped
+//	in[status]=pending,shipped    match any of the listed values
+//	fields=name,price             return only these fields (and id)
+//	page=2&limit=20               page/limit paging (limit is capped at 100)
+//	after=<next_cursor>           cursor paging, see cursor.go
+//	total=false                   skip counting the matching documents
+//
+// Each resource declares the fields that can be filtered and sorted on with a
+// Spec built from its model; values are converted to the type of the model
+// field, so dates and ObjectIDs compare as such.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// kind is how the values of a field are parsed and compared.
+type kind int
+
+const (
+	kindString kind = iota
+	kindNumber
+	kindBool
+	kindDate
+	kindObjectID
+)
+
+// operators lists the comparison operators each kind accepts.
+var operators = map[kind][]string{
+	kindString:   {"eq", "ne", "gt", "gte", "lt", "lte", "like"},
+	kindNumber:   {"eq", "ne", "gt", "gte", "lt", "lte"},
+	kindBool:     {"eq", "ne"},
+	kindDate:     {"eq", "ne", "gt", "gte", "lt", "lte"},
+	kindObjectID: {"eq", "ne"},
+}
+
+var (
+	objectIDType    = reflect.TypeOf(primitive.ObjectID{})
+	timeType        = reflect.TypeOf(time.Time{})
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+	filterParam = regexp.MustCompile(`^filter\[([A-Za-z0-9_]+)\](?:\[([a-z]+)\])?$`)
+	inParam     = regexp.MustCompile(`^in\[([A-Za-z0-9_]+)\]$`)
+)
+
+// DefaultSort is the order of lists that do not ask for one.
+var DefaultSort = bson.D{{Key: "created_at", Value: -1}}
+
+// field is a filterable field of a model.
+type field struct {
+	BSON string
+	Kind kind
+}
+
+// Spec is the whitelist of a resource: the fields that can be filtered and
+// sorted on, and the fields that can be selected.
+type Spec struct {
+	name       string
+	filterable map[string]field
+	selectable map[string]string // JSON name -> BSON name
+}
+
+// NewSpec builds the spec of a model. fields lists the JSON names of the
+// fields that can be filtered and sorted on; when empty, every field holding
+// a string, number, boolean, date or ObjectID can. Every field of the model
+// can be selected.
+func NewSpec(model interface{}, fields ...string) *Spec {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	s := &Spec{name: t.Name(), filterable: map[string]field{}, selectable: map[string]string{}}
+	scalar := map[string]field{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := tagName(f.Tag.Get("json"))
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		bsonName := tagName(f.Tag.Get("bson"))
+		if bsonName == "" {
+			bsonName = strings.ToLower(f.Name)
+		}
+
+		s.selectable[name] = bsonName
+		if k, ok := kindOf(f.Type); ok {
+			scalar[name] = field{BSON: bsonName, Kind: k}
+		}
+	}
+
+	if len(fields) == 0 {
+		s.filterable = scalar
+		return s
+	}
+	for _, name := range fields {
+		fd, ok := scalar[name]
+		if !ok {
+			panic(fmt.Sprintf("query: %s has no filterable field %q", s.name, name))
+		}
+		s.filterable[name] = fd
+	}
+	return s
+}
+
+// kindOf returns the kind of a field type, or false when the field holds a
+// list or a nested document.
+func kindOf(t reflect.Type) (kind, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case objectIDType:
+		return kindObjectID, true
+	case timeType:
+		return kindDate, true
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return kindString, true
+	case reflect.Bool:
+		return kindBool, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return kindNumber, true
+	case reflect.Struct:
+		// Value types such as models.FlexFloat64 hold a number
+		if reflect.PtrTo(t).Implements(unmarshalerType) {
+			return kindNumber, true
+		}
+	}
+	return 0, false
+}
+
+// tagName returns the name part of a struct tag value.
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+// List is a parsed list query.
+type List struct {
+	Filter    bson.M
+	Sort      bson.D
+	Fields    []string
+	Page      int
+	Limit     int
+	Skip      int
+	WithTotal bool
+	spec      *Spec
+	after     *cursor
+	more      bool
+}
+
+// Parse reads the list query of a request. Unknown fields, operators and
+// values that do not match the field type are rejected with a 400.
+func (s *Spec) Parse(c *fiber.Ctx) (*List, error) {
+	l := &List{Filter: bson.M{}, Sort: DefaultSort, spec: s}
+
+	for key, value := range c.Queries() {
+		var err error
+		switch {
+		case key == "sort":
+			l.Sort, err = s.parseSort(value)
+		case key == "fields":
+			l.Fields, err = s.parseFields(value)
+		case strings.HasPrefix(key, "filter["):
+			m := filterParam.FindStringSubmatch(key)
+			if m == nil {
+				return nil, invalid(key, "Use filter[field] or filter[field][operator]")
+			}
+			op := m[2]
+			if op == "" {
+				op = "eq"
+			}
+			err = s.addCondition(l.Filter, key, m[1], op, value)
+		case strings.HasPrefix(key, "in["):
+			m := inParam.FindStringSubmatch(key)
+			if m == nil {
+				return nil, invalid(key, "Use in[field]")
+			}
+			err = s.addIn(l.Filter, key, m[1], value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := l.parsePaging(c); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (s *Spec) parseSort(value string) (bson.D, error) {
+	sortBy := bson.D{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		direction := 1
+		if strings.HasPrefix(part, "-") {
+			direction = -1
+			part = part[1:]
+		} else {
+			part = strings.TrimPrefix(part, "+")
+		}
+		if part == "" {
+			continue
+		}
+		fd, err := s.lookup("sort", part)
+		if err != nil {
+			return nil, err
+		}
+		if seen[fd.BSON] {
+			continue
+		}
+		seen[fd.BSON] = true
+		sortBy = append(sortBy, bson.E{Key: fd.BSON, Value: direction})
+	}
+	if len(sortBy) == 0 {
+		return DefaultSort, nil
+	}
+	return sortBy, nil
+}
+
+func (s *Spec) parseFields(value string) ([]string, error) {
+	var fields []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := s.selectable[name]; !ok {
+			return nil, invalid("fields", fmt.Sprintf("Unknown field %q", name)).With("allowed", sortedKeys(s.selectable))
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+func (s *Spec) addCondition(filter bson.M, param, name, op, raw string) error {
+	fd, err := s.lookup(param, name)
+	if err != nil {
+		return err
+	}
+	if !contains(operators[fd.Kind], op) {
+		return invalid(param, fmt.Sprintf("Unsupported operator %q", op)).With("allowed", operators[fd.Kind])
+	}
+
+	if op == "like" {
+		conditionsOf(filter, fd.BSON)["$regex"] = regexp.QuoteMeta(raw)
+		conditionsOf(filter, fd.BSON)["$options"] = "i"
+		return nil
+	}
+	value, err := fd.parse(raw)
+	if err != nil {
+		return invalid(param, err.Error())
+	}
+	conditionsOf(filter, fd.BSON)["$"+op] = value
+	return nil
+}
+
+func (s *Spec) addIn(filter bson.M, param, name, raw string) error {
+	fd, err := s.lookup(param, name)
+	if err != nil {
+		return err
+	}
+	values := bson.A{}
+	for _, part := range strings.Split(raw, ",") {
+		value, err := fd.parse(strings.TrimSpace(part))
+		if err != nil {
+			return invalid(param, err.Error())
+		}
+		values = append(values, value)
+	}
+	conditionsOf(filter, fd.BSON)["$in"] = values
+	return nil
+}
+
+func (s *Spec) lookup(param, name string) (field, error) {
+	fd, ok := s.filterable[name]
+	if !ok {
+		return field{}, invalid(param, fmt.Sprintf("Unknown field %q", name)).With("allowed", sortedKeys(s.filterable))
+	}
+	return fd, nil
+}
+
+// conditionsOf returns the operator document of a field in filter.
+func conditionsOf(filter bson.M, name string) bson.M {
+	if conditions, ok := filter[name].(bson.M); ok {
+		return conditions
+	}
+	conditions := bson.M{}
+	filter[name] = conditions
+	return conditions
+}
+
+// parse converts a query string value to the type of the field.
+func (f field) parse(raw string) (interface{}, error) {
+	switch f.Kind {
+	case kindNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return n, nil
+	case kindBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return b, nil
+	case kindDate:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a date, use YYYY-MM-DD or RFC3339", raw)
+		}
+		return t, nil
+	case kindObjectID:
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid ObjectID", raw)
+		}
+		return id, nil
+	}
+	return raw, nil
+}
+
+// Apply adds the conditions of the query to filter. A field the handler
+// already filters on keeps both conditions through $and.
+func (l *List) Apply(filter bson.M) bson.M {
+	var both []bson.M
+	for name, conditions := range l.Filter {
+		if _, taken := filter[name]; taken {
+			both = append(both, bson.M{name: conditions})
+			continue
+		}
+		filter[name] = conditions
+	}
+	if len(both) > 0 {
+		if and, ok := filter["$and"].([]bson.M); ok {
+			both = append(and, both...)
+		}
+		filter["$and"] = both
+	}
+	return filter
+}
+
+// Select trims every item of a list to the selected fields and the id. Items
+// are rendered to JSON first, so fields the handler fills in (category names,
+// empty lists) can be selected as well. Without fields= items are returned
+// unchanged.
+func (l *List) Select(items interface{}) (interface{}, error) {
+	if len(l.Fields) == 0 {
+		return items, nil
+	}
+
+	keep := map[string]bool{"id": true, "_id": true}
+	for _, name := range l.Fields {
+		keep[name] = true
+		keep[l.spec.selectable[name]] = true
+	}
+
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		return nil, err
+	}
+	selected := make([]map[string]json.RawMessage, 0, len(rows))
+	for _, row := range rows {
+		for key := range row {
+			if !keep[key] {
+				delete(row, key)
+			}
+		}
+		selected = append(selected, row)
+	}
+	return selected, nil
+}
+
+// invalid is the 400 returned for a malformed query parameter.
+func invalid(param, reason string) *utils.AppError {
+	return utils.BadRequest("Invalid query parameter "+param).
+		With("parameter", param).
+		With("reason", reason)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,183 @@
+package query
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type item struct {
+	ID         primitive.ObjectID  `json:"id" bson:"_id"`
+	Name       string              `json:"name" bson:"name"`
+	Price      float64             `json:"price" bson:"price"`
+	Active     bool                `json:"active" bson:"active"`
+	CategoryID *primitive.ObjectID `json:"category_id" bson:"category_id"`
+	Tags       []string            `json:"tags" bson:"tags"`
+	CreatedAt  time.Time           `json:"created_at" bson:"created_at"`
+}
+
+var (
+	itemSpec = NewSpec(item{})
+	newest   = bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}
+	oid1, _  = primitive.ObjectIDFromHex("507f1f77bcf86cd799439011")
+	oid2, _  = primitive.ObjectIDFromHex("507f1f77bcf86cd799439012")
+)
+
+// parse runs Spec.Parse on a request with the given query string.
+func parse(t *testing.T, s *Spec, rawQuery string) (*List, error) {
+	t.Helper()
+	var list *List
+	var err error
+	app := fiber.New(fiber.Config{Immutable: true})
+	app.Get("/", func(c *fiber.Ctx) error {
+		list, err = s.Parse(c)
+		return nil
+	})
+	if _, testErr := app.Test(httptest.NewRequest("GET", "/?"+rawQuery, nil)); testErr != nil {
+		t.Fatal(testErr)
+	}
+	return list, err
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		check func(t *testing.T, l *List)
+	}{
+		{"defaults", "", func(t *testing.T, l *List) {
+			assertEqual(t, "filter", l.Filter, bson.M{})
+			assertEqual(t, "sort", l.Sort, newest)
+			assertEqual(t, "paging", []int{l.Page, l.Limit, l.Skip}, []int{1, 10, 0})
+			assertEqual(t, "total", l.WithTotal, true)
+		}},
+		{"sort", "sort=-price,%2Bname,price", func(t *testing.T, l *List) {
+			assertEqual(t, "sort", l.Sort, bson.D{{Key: "price", Value: -1}, {Key: "name", Value: 1}})
+		}},
+		{"sort by id uses _id", "sort=id", func(t *testing.T, l *List) {
+			assertEqual(t, "sort", l.Sort, bson.D{{Key: "_id", Value: 1}})
+		}},
+		{"ascending created_at adds _id", "sort=created_at", func(t *testing.T, l *List) {
+			assertEqual(t, "sort", l.Sort, bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+		}},
+		{"filter shorthand", "filter[name]=lamp", func(t *testing.T, l *List) {
+			assertEqual(t, "filter", l.Filter, bson.M{"name": bson.M{"$eq": "lamp"}})
+		}},
+		{"number range", "filter[price][gte]=10&filter[price][lt]=20.5", func(t *testing.T, l *List) {
+			assertEqual(t, "filter", l.Filter, bson.M{"price": bson.M{"$gte": 10.0, "$lt": 20.5}})
+		}},
+		{"like is escaped and case-insensitive", "filter[name][like]=a.b", func(t *testing.T, l *List) {
+			assertEqual(t, "filter", l.Filter, bson.M{"name": bson.M{"$regex": `a\.b`, "$options": "i"}})
+		}},
+		{"bool", "filter[active][ne]=false", func(t *testing.T, l *List) {
+			assertEqual(t, "filter", l.Filter, bson.M{"active": bson.M{"$ne": false}})
+		}},
+		{"date", "filter[created_at][gte]=2024-01-02", func(t *testing.T, l *List) {
+			assertEqual(t, "filter", l.Filter, bson.M{"created_at": bson.M{"$gte": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}})
+		}},
+		{"in with ObjectIDs", "in[category_id]=" + oid1.Hex() + "," + oid2.Hex(), func(t *testing.T, l *List) {
+			assertEqual(t, "filter", l.Filter, bson.M{"category_id": bson.M{"$in": bson.A{oid1, oid2}}})
+		}},
+		{"fields", "fields=name,price", func(t *testing.T, l *List) {
+			assertEqual(t, "fields", l.Fields, []string{"name", "price"})
+		}},
+		{"page and limit", "page=3&limit=20", func(t *testing.T, l *List) {
+			assertEqual(t, "paging", []int{l.Page, l.Limit, l.Skip}, []int{3, 20, 40})
+		}},
+		{"limit is capped", "limit=500", func(t *testing.T, l *List) {
+			assertEqual(t, "limit", l.Limit, utils.MaxPageSize)
+		}},
+		{"total off", "total=false", func(t *testing.T, l *List) {
+			assertEqual(t, "total", l.WithTotal, false)
+		}},
+		{"after", "page=4&after=" + cursor{CreatedAt: time.Unix(1700000000, 0).UTC(), ID: oid1}.encode(), func(t *testing.T, l *List) {
+			assertEqual(t, "skip", l.Skip, 0)
+			assertEqual(t, "total", l.WithTotal, false)
+			assertEqual(t, "after", l.after, &cursor{CreatedAt: time.Unix(1700000000, 0).UTC(), ID: oid1})
+		}},
+		{"after with total", "after=" + cursor{ID: oid1}.encode() + "&total=1", func(t *testing.T, l *List) {
+			assertEqual(t, "total", l.WithTotal, true)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := parse(t, itemSpec, tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%s): %v", tt.query, err)
+			}
+			tt.check(t, l)
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		parameter string
+	}{
+		{"unknown sort field", "sort=colour", "sort"},
+		{"list fields cannot be sorted", "sort=tags", "sort"},
+		{"unknown filter field", "filter[colour]=red", "filter[colour]"},
+		{"malformed filter", "filter[name=lamp", "filter[name"},
+		{"unknown operator", "filter[name][regex]=x", "filter[name][regex]"},
+		{"operator not allowed for kind", "filter[price][like]=1", "filter[price][like]"},
+		{"not a number", "filter[price]=cheap", "filter[price]"},
+		{"not a boolean", "filter[active]=maybe", "filter[active]"},
+		{"not a date", "filter[created_at][gt]=yesterday", "filter[created_at][gt]"},
+		{"not an ObjectID", "filter[id]=123", "filter[id]"},
+		{"bad value in list", "in[price]=1,two", "in[price]"},
+		{"malformed in", "in[price", "in[price"},
+		{"unknown selected field", "fields=name,colour", "fields"},
+		{"bad total", "total=maybe", "total"},
+		{"cursor needs created_at order", "sort=price&after=" + cursor{ID: oid1}.encode(), "after"},
+		{"malformed cursor", "after=not-a-cursor", "after"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(t, itemSpec, tt.query)
+			var appErr *utils.AppError
+			if !errors.As(err, &appErr) {
+				t.Fatalf("Parse(%s) error = %v, want an AppError", tt.query, err)
+			}
+			if appErr.Status != fiber.StatusBadRequest {
+				t.Errorf("status = %d, want 400", appErr.Status)
+			}
+			if appErr.Details["parameter"] != tt.parameter {
+				t.Errorf("parameter = %v, want %s", appErr.Details["parameter"], tt.parameter)
+			}
+		})
+	}
+}
+
+func TestNewSpecWhitelist(t *testing.T) {
+	s := NewSpec(item{}, "name")
+	if _, err := parse(t, s, "filter[name]=lamp&fields=price"); err != nil {
+		t.Errorf("whitelisted filter: %v", err)
+	}
+	if _, err := parse(t, s, "filter[price]=1"); err == nil {
+		t.Error("filter on a field outside the whitelist was accepted")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewSpec accepted a field that cannot be filtered")
+		}
+	}()
+	NewSpec(item{}, "tags")
+}
+
+func assertEqual(t *testing.T, what string, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %#v, want %#v", what, got, want)
+	}
+}
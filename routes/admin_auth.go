@@ -7,29 +7,40 @@ import (
 	"time"
 
 	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
 	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+	"fiber-ecommerce/validation"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Admin authentication models
 type AdminLoginRequest struct {
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 type AdminUpdateRequest struct {
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Name            string `json:"name" validate:"required"`
+	Password        string `json:"password"`
+	CurrentPassword string `json:"current_password"`
+}
+
+type AdminPasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
 }
 
 type AdminAuthResponse struct {
-	Token string       `json:"token"`
-	Admin models.Admin `json:"admin"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	Admin        models.Admin `json:"admin"`
 }
 
 func AdminAuthRoutes(app fiber.Router, db *mongo.Client) {
@@ -38,15 +49,15 @@ func AdminAuthRoutes(app fiber.Router, db *mongo.Client) {
 	// Admin Login - only login with existing admin
 	adminAuth.Post("/login", func(c *fiber.Ctx) error {
 		var req AdminLoginRequest
-		if err := c.BodyParser(&req); err != nil {
-			log.Printf("Error parsing request body: %v", err)
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		log.Printf("Login attempt for admin: %s", req.Name)
-
-		if req.Name == "" || req.Password == "" {
-			return c.Status(400).JSON(fiber.Map{"error": "Name and password are required"})
+		// Refuse early while the account or the client IP is locked out
+		accountKey, ipKey := accountThrottle("admin", req.Name), ipThrottle(c.IP())
+		if lockout := loginLockedFor(db, accountKey, ipKey); lockout > 0 {
+			recordLoginAttempt(db, c, "admin", req.Name, nil, false, models.LoginReasonLockedOut)
+			return tooManyLoginAttempts(c, lockout)
 		}
 
 		collection := config.GetCollection(db, "admins")
@@ -55,94 +66,177 @@ func AdminAuthRoutes(app fiber.Router, db *mongo.Client) {
 		var admin models.Admin
 		err := collection.FindOne(context.TODO(), bson.M{"name": req.Name}).Decode(&admin)
 		if err != nil {
-			log.Printf("Admin not found: %s, error: %v", req.Name, err)
-			return c.Status(401).JSON(fiber.Map{"error": "Invalid credentials"})
+			registerLoginFailure(db, accountKey, ipKey)
+			recordLoginAttempt(db, c, "admin", req.Name, nil, false, models.LoginReasonUnknownAccount)
+			return utils.Unauthorized("Invalid credentials")
 		}
 
-		log.Printf("Found admin: %s, stored password hash: %s", admin.Name, admin.Password)
-		log.Printf("Input password: %s", req.Password)
-
 		// Check password
 		err = bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.Password))
 		if err != nil {
-			log.Printf("Password comparison failed for admin %s: %v", admin.Name, err)
-			
-			// Debug: Generate hash for the input password to compare
-			testHash, _ := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-			log.Printf("Generated hash for input password '%s': %s", req.Password, string(testHash))
-			
-			return c.Status(401).JSON(fiber.Map{"error": "Invalid credentials"})
+			registerLoginFailure(db, accountKey, ipKey)
+			recordLoginAttempt(db, c, "admin", req.Name, &admin.ID, false, models.LoginReasonInvalidPassword)
+			return utils.Unauthorized("Invalid credentials")
 		}
 
-		log.Printf("Password verification successful for admin: %s", admin.Name)
+		if !admin.IsActive() {
+			recordLoginAttempt(db, c, "admin", req.Name, &admin.ID, false, models.LoginReasonAccountDeactivated)
+			return utils.Unauthorized("Account is deactivated. Please contact a superadmin.")
+		}
 
 		admin.Password = "" // Don't return password
+		admin.Role = admin.EffectiveRole()
+
+		session, refreshToken, err := createSession(db, c, "admin", admin.ID)
+		if err != nil {
+			log.Printf("Failed to create session: %v", err)
+			return utils.Internal("Failed to create session")
+		}
 
 		// Generate JWT token
-		token, err := generateAdminJWT(admin.ID.Hex(), admin.Name)
+		token, err := generateAdminJWT(admin.ID.Hex(), admin.Name, admin.EffectiveRole(), session.ID.Hex())
 		if err != nil {
 			log.Printf("Failed to generate JWT token: %v", err)
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
+			return utils.Internal("Failed to generate token")
 		}
 
-		log.Printf("Login successful for admin: %s", admin.Name)
+		clearLoginFailures(db, accountKey)
+		recordLoginAttempt(db, c, "admin", req.Name, &admin.ID, true, models.LoginReasonSuccess)
 
 		return c.JSON(AdminAuthResponse{
-			Token: token,
-			Admin: admin,
+			Token:        token,
+			RefreshToken: refreshToken,
+			Admin:        admin,
 		})
 	})
 
-	// Admin Update - update existing admin (protected route)
-	adminAuth.Put("/update", func(c *fiber.Ctx) error {
-		var req AdminUpdateRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	// Admin Refresh - exchange a refresh token for a new token pair
+	adminAuth.Post("/refresh", func(c *fiber.Ctx) error {
+		var req models.RefreshTokenRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		if req.Name == "" || req.Password == "" {
-			return c.Status(400).JSON(fiber.Map{"error": "Name and password are required"})
+		session, refreshToken, err := rotateSession(db, req.RefreshToken, "admin")
+		if err != nil {
+			if err == errInvalidRefreshToken {
+				return utils.Unauthorized("Invalid or expired refresh token")
+			}
+			return utils.Internal("Failed to refresh session")
 		}
 
 		collection := config.GetCollection(db, "admins")
-
-		// Find the single admin
 		var admin models.Admin
-		err := collection.FindOne(context.TODO(), bson.M{}).Decode(&admin)
+		if err := collection.FindOne(context.TODO(), bson.M{"_id": session.SubjectID}).Decode(&admin); err != nil || !admin.IsActive() {
+			revokeSession(db, session.ID.Hex())
+			return utils.Unauthorized("Admin not found or deactivated")
+		}
+
+		admin.Password = "" // Don't return password
+		admin.Role = admin.EffectiveRole()
+
+		token, err := generateAdminJWT(admin.ID.Hex(), admin.Name, admin.Role, session.ID.Hex())
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Admin not found"})
+			return utils.Internal("Failed to generate token")
+		}
+
+		return c.JSON(AdminAuthResponse{
+			Token:        token,
+			RefreshToken: refreshToken,
+			Admin:        admin,
+		})
+	})
+
+	// Admin Logout - revoke the current session
+	adminAuth.Post("/logout", middleware.AdminJWTMiddleware(), func(c *fiber.Ctx) error {
+		sessionID, _ := c.Locals("session_id").(string)
+		if err := revokeSession(db, sessionID); err != nil {
+			return utils.Internal("Failed to log out")
 		}
 
-		// Hash new password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		return c.JSON(fiber.Map{"message": "Logged out successfully"})
+	})
+
+	// Admin Logout All - revoke every session of the current admin
+	adminAuth.Post("/logout-all", middleware.AdminJWTMiddleware(), func(c *fiber.Ctx) error {
+		adminID, _ := c.Locals("admin_id").(string)
+		id, err := primitive.ObjectIDFromHex(adminID)
+		if err != nil {
+			return utils.BadRequest("Invalid admin ID")
+		}
+
+		revoked, err := revokeAllSessions(db, "admin", id)
+		if err != nil {
+			return utils.Internal("Failed to log out")
+		}
+
+		return c.JSON(fiber.Map{
+			"message":          "Logged out from all devices",
+			"revoked_sessions": revoked,
+		})
+	})
+
+	// Admin Update - update the logged-in admin's name (protected route)
+	adminAuth.Put("/update", middleware.AdminJWTMiddleware(), func(c *fiber.Ctx) error {
+		var req AdminUpdateRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		admin, err := currentAdmin(c, db)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to hash password"})
+			return utils.NotFound("Admin not found")
+		}
+
+		collection := config.GetCollection(db, "admins")
+
+		taken, err := collection.CountDocuments(context.TODO(), bson.M{"name": req.Name, "_id": bson.M{"$ne": admin.ID}})
+		if err != nil {
+			return utils.Internal("Failed to update admin")
+		}
+		if taken > 0 {
+			return utils.Conflict("Admin with this name already exists")
 		}
 
-		// Update admin
 		updateData := bson.M{
 			"name":       req.Name,
-			"password":   string(hashedPassword),
 			"updated_at": time.Now(),
 		}
 
+		// Changing the password here requires the current password as well
+		if req.Password != "" {
+			if bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.CurrentPassword)) != nil {
+				return utils.Unauthorized("Current password is incorrect")
+			}
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return utils.Internal("Failed to hash password")
+			}
+			updateData["password"] = string(hashedPassword)
+		}
+
 		_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": admin.ID}, bson.M{"$set": updateData})
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update admin"})
+			return utils.Internal("Failed to update admin")
 		}
 
 		// Get updated admin
 		err = collection.FindOne(context.TODO(), bson.M{"_id": admin.ID}).Decode(&admin)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch updated admin"})
+			return utils.Internal("Failed to fetch updated admin")
 		}
 
 		admin.Password = "" // Don't return password
+		admin.Role = admin.EffectiveRole()
 
-		// Generate new JWT token with updated info
-		token, err := generateAdminJWT(admin.ID.Hex(), admin.Name)
+		// Generate new JWT token with updated info for the current session
+		sessionID, _ := c.Locals("session_id").(string)
+		if req.Password != "" {
+			revokeOtherSessions(db, "admin", admin.ID, sessionID)
+		}
+		token, err := generateAdminJWT(admin.ID.Hex(), admin.Name, admin.Role, sessionID)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
+			return utils.Internal("Failed to generate token")
 		}
 
 		return c.JSON(AdminAuthResponse{
@@ -151,56 +245,159 @@ func AdminAuthRoutes(app fiber.Router, db *mongo.Client) {
 		})
 	})
 
-	// Admin Profile - get current admin info (protected route)
-	adminAuth.Get("/profile", func(c *fiber.Ctx) error {
+	// Admin Password - change the logged-in admin's password (protected route)
+	adminAuth.Put("/password", middleware.AdminJWTMiddleware(), func(c *fiber.Ctx) error {
+		var req AdminPasswordChangeRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		admin, err := currentAdmin(c, db)
+		if err != nil {
+			return utils.NotFound("Admin not found")
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.CurrentPassword)) != nil {
+			return utils.Unauthorized("Current password is incorrect")
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return utils.Internal("Failed to hash password")
+		}
+
 		collection := config.GetCollection(db, "admins")
+		_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": admin.ID}, bson.M{"$set": bson.M{
+			"password":   string(hashedPassword),
+			"updated_at": time.Now(),
+		}})
+		if err != nil {
+			return utils.Internal("Failed to update password")
+		}
 
-		// Find the single admin
-		var admin models.Admin
-		err := collection.FindOne(context.TODO(), bson.M{}).Decode(&admin)
+		// Sign out every other device that used the old password
+		sessionID, _ := c.Locals("session_id").(string)
+		revokeOtherSessions(db, "admin", admin.ID, sessionID)
+
+		return c.JSON(fiber.Map{"message": "Password changed successfully"})
+	})
+
+	// Admin Profile - get current admin info (protected route)
+	adminAuth.Get("/profile", middleware.AdminJWTMiddleware(), func(c *fiber.Ctx) error {
+		admin, err := currentAdmin(c, db)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Admin not found"})
+			return utils.NotFound("Admin not found")
 		}
 
 		admin.Password = "" // Don't return password
+		admin.Role = admin.EffectiveRole()
 		return c.JSON(admin)
 	})
 
-	// Debug endpoint to check admin existence and password hash
-	adminAuth.Get("/debug", func(c *fiber.Ctx) error {
-		collection := config.GetCollection(db, "admins")
+	// Diagnostics - counts and configuration health only, never credentials
+	adminAuth.Get("/diagnostics", middleware.AdminJWTMiddleware(superAdminRoles...), func(c *fiber.Ctx) error {
+		adminsCollection := config.GetCollection(db, "admins")
+		since := time.Now().Add(-24 * time.Hour)
+
+		activeSuperAdmins, _ := adminsCollection.CountDocuments(context.TODO(), activeSuperAdminFilter())
+		deactivatedAdmins, _ := adminsCollection.CountDocuments(context.TODO(), bson.M{"deactivated_at": bson.M{"$ne": nil}})
+		totalUsers, _ := config.GetCollection(db, "users").CountDocuments(context.TODO(), bson.M{})
+		activeSessions, _ := config.GetCollection(db, "sessions").CountDocuments(context.TODO(), bson.M{
+			"revoked_at": nil,
+			"expires_at": bson.M{"$gt": time.Now()},
+		})
+		failedLogins, _ := config.GetCollection(db, "login_attempts").CountDocuments(context.TODO(), bson.M{
+			"success":    false,
+			"created_at": bson.M{"$gte": since},
+		})
 
-		var admin models.Admin
-		err := collection.FindOne(context.TODO(), bson.M{"name": "admin"}).Decode(&admin)
-		if err != nil {
-			return c.JSON(fiber.Map{
-				"error":       "Admin not found",
-				"admin_count": getAdminCount(collection),
-			})
+		databaseStatus := "ok"
+		if err := db.Ping(context.TODO(), nil); err != nil {
+			databaseStatus = "unreachable"
 		}
 
-		// Test password hash
-		testPassword := "bct123"
-		err = bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(testPassword))
-		passwordValid := err == nil
+		warnings := []string{}
+		if os.Getenv("JWT_SECRET") == "" {
+			warnings = append(warnings, "JWT_SECRET is not set; the built-in development secret is in use")
+		}
+		if os.Getenv("MONGODB_URI") == "" {
+			warnings = append(warnings, "MONGODB_URI is not set; the default local connection string is in use")
+		}
+		if activeSuperAdmins == 0 {
+			warnings = append(warnings, "No active superadmin exists")
+		}
 
 		return c.JSON(fiber.Map{
-			"admin_exists":    true,
-			"admin_name":      admin.Name,
-			"password_hash":   admin.Password,
-			"test_password":   testPassword,
-			"password_valid":  passwordValid,
-			"admin_count":     getAdminCount(collection),
+			"admins": fiber.Map{
+				"total":              getAdminCount(adminsCollection),
+				"active_superadmins": activeSuperAdmins,
+				"deactivated":        deactivatedAdmins,
+			},
+			"users": fiber.Map{
+				"total": totalUsers,
+			},
+			"sessions": fiber.Map{
+				"active": activeSessions,
+			},
+			"security": fiber.Map{
+				"failed_logins_last_24h": failedLogins,
+			},
+			"config": fiber.Map{
+				"database":               databaseStatus,
+				"jwt_secret_configured":  os.Getenv("JWT_SECRET") != "",
+				"mongodb_uri_configured": os.Getenv("MONGODB_URI") != "",
+			},
+			"warnings":  warnings,
+			"timestamp": time.Now(),
 		})
 	})
 }
 
+// currentAdmin loads the admin identified by the admin_id of the request's JWT.
+func currentAdmin(c *fiber.Ctx, db *mongo.Client) (models.Admin, error) {
+	adminID, _ := c.Locals("admin_id").(string)
+	id, err := primitive.ObjectIDFromHex(adminID)
+	if err != nil {
+		return models.Admin{}, err
+	}
+
+	collection := config.GetCollection(db, "admins")
+	var admin models.Admin
+	err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&admin)
+	return admin, err
+}
+
+// activeSuperAdminFilter matches active superadmins, including admins created
+// before roles existed.
+func activeSuperAdminFilter() bson.M {
+	return bson.M{
+		"role":           bson.M{"$in": bson.A{models.RoleSuperAdmin, "", nil}},
+		"deactivated_at": nil,
+	}
+}
+
+// isLastActiveSuperAdmin reports whether admin is the only remaining active superadmin.
+func isLastActiveSuperAdmin(db *mongo.Client, admin models.Admin) (bool, error) {
+	if !admin.IsActive() || admin.EffectiveRole() != models.RoleSuperAdmin {
+		return false, nil
+	}
+
+	filter := activeSuperAdminFilter()
+	filter["_id"] = bson.M{"$ne": admin.ID}
+
+	others, err := config.GetCollection(db, "admins").CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return false, err
+	}
+	return others == 0, nil
+}
+
 func getAdminCount(collection *mongo.Collection) int64 {
 	count, _ := collection.CountDocuments(context.TODO(), bson.M{})
 	return count
 }
 
-func generateAdminJWT(adminID, adminName string) (string, error) {
+func generateAdminJWT(adminID, adminName, role, sessionID string) (string, error) {
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		jwtSecret = "your-super-secret-jwt-key-here"
@@ -209,8 +406,10 @@ func generateAdminJWT(adminID, adminName string) (string, error) {
 	claims := jwt.MapClaims{
 		"admin_id":   adminID,
 		"admin_name": adminName,
+		"role":       role,
+		"sid":        sessionID,
 		"type":       "admin",
-		"exp":        time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
+		"exp":        time.Now().Add(accessTokenTTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
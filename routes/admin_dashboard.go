@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -19,6 +22,7 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 	dashboard := app.Group("/admin/dashboard")
 
 	// Apply admin authentication middleware
+	dashboard.Use(middleware.AdminJWTMiddleware())
 
 	// Get comprehensive dashboard statistics
 	dashboard.Get("/stats", func(c *fiber.Ctx) error {
@@ -49,34 +53,34 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 		})
 
 		// Order statistics
-		totalOrders, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{})
-		pendingOrders, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{"status": "pending"})
-		confirmedOrders, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{"status": "confirmed"})
-		shippedOrders, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{"status": "shipped"})
-		deliveredOrders, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{"status": "delivered"})
-		cancelledOrders, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{"status": "cancelled"})
-
-		ordersToday, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{
+		totalOrders, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{}))
+		pendingOrders, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{"status": statusFilter(models.OrderStatusPending)}))
+		confirmedOrders, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{"status": "confirmed"}))
+		shippedOrders, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{"status": "shipped"}))
+		deliveredOrders, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{"status": "delivered"}))
+		cancelledOrders, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{"status": "cancelled"}))
+
+		ordersToday, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{
 			"created_at": bson.M{"$gte": startOfToday},
-		})
-		ordersThisWeek, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{
+		}))
+		ordersThisWeek, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{
 			"created_at": bson.M{"$gte": startOfWeek},
-		})
-		ordersThisMonth, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{
+		}))
+		ordersThisMonth, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{
 			"created_at": bson.M{"$gte": startOfMonth},
-		})
+		}))
 
 		// Product statistics
-		totalProducts, _ := productsCollection.CountDocuments(context.TODO(), bson.M{})
-		discountedProducts, _ := productsCollection.CountDocuments(context.TODO(), bson.M{
+		totalProducts, _ := productsCollection.CountDocuments(context.TODO(), notTrashed(bson.M{}))
+		discountedProducts, _ := productsCollection.CountDocuments(context.TODO(), notTrashed(bson.M{
 			"discount": bson.M{"$ne": "", "$exists": true},
-		})
+		}))
 
 		// Review statistics
-		totalReviews, _ := reviewsCollection.CountDocuments(context.TODO(), bson.M{})
-		reviewsThisMonth, _ := reviewsCollection.CountDocuments(context.TODO(), bson.M{
+		totalReviews, _ := reviewsCollection.CountDocuments(context.TODO(), notTrashed(bson.M{}))
+		reviewsThisMonth, _ := reviewsCollection.CountDocuments(context.TODO(), notTrashed(bson.M{
 			"created_at": bson.M{"$gte": startOfMonth},
-		})
+		}))
 
 		return c.JSON(fiber.Map{
 			"users": fiber.Map{
@@ -125,7 +129,7 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 
 		// Get recent orders
 		ordersCollection := config.GetCollection(db, "orders")
-		ordersCursor, _ := ordersCollection.Find(context.TODO(), bson.M{},
+		ordersCursor, _ := ordersCollection.Find(context.TODO(), notTrashed(bson.M{}),
 			options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit/2)))
 
 		var recentOrders []bson.M
@@ -134,7 +138,7 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 
 		// Get recent reviews
 		reviewsCollection := config.GetCollection(db, "reviews")
-		reviewsCursor, _ := reviewsCollection.Find(context.TODO(), bson.M{},
+		reviewsCursor, _ := reviewsCollection.Find(context.TODO(), notTrashed(bson.M{}),
 			options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit/4)))
 
 		var recentReviews []bson.M
@@ -202,10 +206,10 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 
 		pipeline := []bson.M{
 			{
-				"$match": bson.M{
+				"$match": notTrashed(bson.M{
 					"created_at": bson.M{"$gte": startDate},
 					"status":     bson.M{"$ne": "cancelled"},
-				},
+				}),
 			},
 			{
 				"$group": bson.M{
@@ -230,7 +234,7 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 
 		cursor, err := ordersCollection.Aggregate(context.TODO(), pipeline)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to get sales analytics"})
+			return utils.Internal("Failed to get sales analytics")
 		}
 		defer cursor.Close(context.TODO())
 
@@ -249,6 +253,9 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 		ordersCollection := config.GetCollection(db, "orders")
 
 		pipeline := []bson.M{
+			{
+				"$match": notTrashed(bson.M{}),
+			},
 			{
 				"$unwind": "$products",
 			},
@@ -263,24 +270,28 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 				"$sort": bson.M{"total_sold": -1},
 			},
 			{
-				"$limit": int64(limit),
-			},
-			{
+				// Trashed products drop out of the ranking, so the limit
+				// applies after the lookup
 				"$lookup": bson.M{
-					"from":         "products",
-					"localField":   "_id",
-					"foreignField": "_id",
-					"as":           "product_info",
+					"from": "products",
+					"let":  bson.M{"product_id": "$_id"},
+					"pipeline": []bson.M{
+						{"$match": notTrashed(bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$product_id"}}})},
+					},
+					"as": "product_info",
 				},
 			},
 			{
 				"$unwind": "$product_info",
 			},
+			{
+				"$limit": int64(limit),
+			},
 		}
 
 		cursor, err := ordersCollection.Aggregate(context.TODO(), pipeline)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to get top products"})
+			return utils.Internal("Failed to get top products")
 		}
 		defer cursor.Close(context.TODO())
 
@@ -339,7 +350,7 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 
 		cursor, err := usersCollection.Aggregate(context.TODO(), pipeline)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to get user growth analytics"})
+			return utils.Internal("Failed to get user growth analytics")
 		}
 		defer cursor.Close(context.TODO())
 
@@ -354,30 +365,98 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 
 	// Get low stock alerts (if you add inventory management)
 	dashboard.Get("/alerts", func(c *fiber.Ctx) error {
-		// This is a placeholder for future inventory management
-		// You can expand this when you add stock tracking to products
+		staleDays := defaultStaleContractDays
+		if v, err := strconv.Atoi(c.Query("stale_days")); err == nil && v > 0 {
+			staleDays = v
+		}
 
-		alerts := []fiber.Map{
-			{
-				"type":        "info",
-				"title":       "System Status",
-				"description": "All systems operational",
+		alerts := []fiber.Map{}
+
+		// Inventory: products that ran out and products at their reorder threshold
+		productsCollection := config.GetCollection(db, "products")
+		productOpts := options.Find().SetLimit(maxAlertsPerKind).SetSort(bson.D{{Key: "count", Value: 1}})
+
+		var outOfStock []models.Product
+		if cursor, err := productsCollection.Find(context.TODO(), notTrashed(bson.M{
+			"$expr": bson.M{"$lte": bson.A{availableStockExpr, 0}},
+		}), productOpts); err == nil {
+			cursor.All(context.TODO(), &outOfStock)
+		}
+		for _, p := range outOfStock {
+			alerts = append(alerts, fiber.Map{
+				"type":        "error",
+				"kind":        "out_of_stock",
+				"title":       "Out of Stock",
+				"description": fmt.Sprintf("%s has no available stock (count %d, reserved %d)", p.Name, p.Count, p.Reserved),
+				"entity_type": "product",
+				"entity_id":   p.ID,
 				"timestamp":   time.Now(),
-			},
+			})
+		}
+
+		var lowStock []models.Product
+		if cursor, err := productsCollection.Find(context.TODO(), notTrashed(bson.M{
+			"reorder_threshold": bson.M{"$ne": nil},
+			"$expr": bson.M{"$and": bson.A{
+				bson.M{"$gt": bson.A{availableStockExpr, 0}},
+				bson.M{"$lte": bson.A{availableStockExpr, "$reorder_threshold"}},
+			}},
+		}), productOpts); err == nil {
+			cursor.All(context.TODO(), &lowStock)
+		}
+		for _, p := range lowStock {
+			alerts = append(alerts, fiber.Map{
+				"type":        "warning",
+				"kind":        "low_stock",
+				"title":       "Low Stock",
+				"description": fmt.Sprintf("%s has %d available, reorder threshold is %d", p.Name, p.Available(), *p.ReorderThreshold),
+				"entity_type": "product",
+				"entity_id":   p.ID,
+				"timestamp":   time.Now(),
+			})
+		}
+
+		// Contracts with an open balance and no update for staleDays
+		var staleContracts []models.Contract
+		contractOpts := options.Find().SetLimit(maxAlertsPerKind).SetSort(bson.D{{Key: "updated_at", Value: 1}})
+		if cursor, err := config.GetCollection(db, "contracts").Find(context.TODO(), notTrashed(bson.M{
+			"updated_at": bson.M{"$lt": time.Now().AddDate(0, 0, -staleDays)},
+			"$expr": bson.M{"$lt": bson.A{
+				bson.M{"$add": bson.A{toDoubleExpr("$pay_card"), toDoubleExpr("$pay_cash")}},
+				toDoubleExpr("$contract_amount"),
+			}},
+		}), contractOpts); err == nil {
+			cursor.All(context.TODO(), &staleContracts)
+		}
+		for _, ct := range staleContracts {
+			name := ct.ID.Hex()
+			if ct.ClientName != nil && *ct.ClientName != "" {
+				name = *ct.ClientName
+			}
+			alerts = append(alerts, fiber.Map{
+				"type":        "warning",
+				"kind":        "stale_contract",
+				"title":       "Stale Contract",
+				"description": fmt.Sprintf("Contract for %s is not fully paid and has not been updated for %d days", name, int(time.Since(ct.UpdatedAt).Hours()/24)),
+				"entity_type": "contract",
+				"entity_id":   ct.ID,
+				"timestamp":   time.Now(),
+			})
 		}
 
 		// Check for old pending orders (more than 7 days)
 		ordersCollection := config.GetCollection(db, "orders")
 		sevenDaysAgo := time.Now().AddDate(0, 0, -7)
 
-		oldPendingOrders, _ := ordersCollection.CountDocuments(context.TODO(), bson.M{
-			"status":     "pending",
+		oldPendingOrders, _ := ordersCollection.CountDocuments(context.TODO(), notTrashed(bson.M{
+			"status":     statusFilter(models.OrderStatusPending),
 			"created_at": bson.M{"$lt": sevenDaysAgo},
-		})
+		}))
 
 		if oldPendingOrders > 0 {
 			alerts = append(alerts, fiber.Map{
 				"type":        "warning",
+				"kind":        "old_pending_orders",
 				"title":       "Old Pending Orders",
 				"description": fmt.Sprintf("%d orders have been pending for more than 7 days", oldPendingOrders),
 				"timestamp":   time.Now(),
@@ -397,6 +476,7 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 		if inactiveUsersWithRecentOrders > 0 {
 			alerts = append(alerts, fiber.Map{
 				"type":        "info",
+				"kind":        "inactive_users",
 				"title":       "Inactive Users with Recent Activity",
 				"description": fmt.Sprintf("%d inactive users have logged in recently", inactiveUsersWithRecentOrders),
 				"timestamp":   time.Now(),
@@ -404,9 +484,32 @@ func AdminDashboardRoutes(app fiber.Router, db *mongo.Client) {
 			})
 		}
 
+		if len(alerts) == 0 {
+			alerts = append(alerts, fiber.Map{
+				"type":        "info",
+				"kind":        "system",
+				"title":       "System Status",
+				"description": "All systems operational",
+				"timestamp":   time.Now(),
+			})
+		}
+
 		return c.JSON(fiber.Map{
 			"alerts": alerts,
 			"total":  len(alerts),
 		})
 	})
 }
+
+const (
+	// maxAlertsPerKind caps the entity alerts of each kind returned at once.
+	maxAlertsPerKind = 50
+	// defaultStaleContractDays is how long an unpaid contract may go without updates.
+	defaultStaleContractDays = 30
+)
+
+// toDoubleExpr converts a numeric or numeric-string field to a double, using 0
+// for missing or malformed values.
+func toDoubleExpr(field string) bson.M {
+	return bson.M{"$convert": bson.M{"input": field, "to": "double", "onError": 0, "onNull": 0}}
+}
@@ -0,0 +1,314 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditIgnoredFields are bookkeeping fields left out of audit diffs.
+var auditIgnoredFields = map[string]bool{
+	"_id":        true,
+	"updated_at": true,
+}
+
+// auditRedactedFields are recorded as changed without their values.
+var auditRedactedFields = map[string]bool{
+	"password": true,
+}
+
+// auditRedacted replaces the values of redacted fields.
+const auditRedacted = "[redacted]"
+
+// auditResources maps resources without a trash to their collection and the
+// roles allowed to read their history.
+var auditResources = map[string]trashResource{
+	"users":  {Collection: "users", Roles: salesRoles},
+	"admins": {Collection: "admins", Roles: superAdminRoles},
+}
+
+func ensureAuditIndexes(db *mongo.Client) {
+	collection := config.GetCollection(db, "audit_log")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "collection", Value: 1}, {Key: "document_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create audit log indexes: %v", err)
+	}
+}
+
+// toAuditMap converts a document (struct, bson.M or nil) to a flat map.
+func toAuditMap(doc interface{}) bson.M {
+	switch v := doc.(type) {
+	case nil:
+		return bson.M{}
+	case bson.M:
+		return v
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return bson.M{}
+	}
+	m := bson.M{}
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return bson.M{}
+	}
+	return m
+}
+
+// auditDiff returns the top-level fields that differ between two documents.
+func auditDiff(before, after interface{}) map[string]models.AuditChange {
+	b, a := toAuditMap(before), toAuditMap(after)
+	changes := map[string]models.AuditChange{}
+
+	keys := map[string]bool{}
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range a {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		if auditIgnoredFields[k] {
+			continue
+		}
+		bv, inBefore := b[k]
+		av, inAfter := a[k]
+		if inBefore && inAfter && reflect.DeepEqual(bv, av) {
+			continue
+		}
+		if auditRedactedFields[k] {
+			if inBefore {
+				bv = auditRedacted
+			}
+			if inAfter {
+				av = auditRedacted
+			}
+		}
+		changes[k] = models.AuditChange{Before: bv, After: av}
+	}
+	return changes
+}
+
+// auditActor identifies who made the request: an admin, a signed-in user or
+// an anonymous visitor.
+func auditActor(c *fiber.Ctx) (actorType, id, name string) {
+	if adminID, _ := c.Locals("admin_id").(string); adminID != "" {
+		name, _ = c.Locals("admin_name").(string)
+		return "admin", adminID, name
+	}
+	if userID, _ := c.Locals("user_id").(string); userID != "" {
+		name, _ = c.Locals("user_phone").(string)
+		return "user", userID, name
+	}
+	return "anonymous", "", ""
+}
+
+// insertAudit stores an audit event, logging failures instead of failing the request.
+func insertAudit(db *mongo.Client, event models.AuditEvent) {
+	if len(event.Changes) == 0 && event.Action == models.AuditActionUpdate {
+		return
+	}
+	event.CreatedAt = time.Now()
+	if _, err := config.GetCollection(db, "audit_log").InsertOne(context.TODO(), event); err != nil {
+		log.Printf("Failed to record audit event for %s %s: %v", event.Collection, event.DocumentID.Hex(), err)
+	}
+}
+
+// redactAuditHistory removes personal data from past audit events: the
+// values of fields in the events of the given documents, and the name of the
+// user recorded as actor. Fields are still listed as changed.
+func redactAuditHistory(ctx context.Context, db *mongo.Client, collection string, ids []primitive.ObjectID, fields []string, actorID string) error {
+	auditLog := config.GetCollection(db, "audit_log")
+	if len(ids) > 0 {
+		for _, field := range fields {
+			for _, side := range []string{"before", "after"} {
+				path := "changes." + field + "." + side
+				_, err := auditLog.UpdateMany(ctx,
+					bson.M{"collection": collection, "document_id": bson.M{"$in": ids}, path: bson.M{"$exists": true}},
+					bson.M{"$set": bson.M{path: auditRedacted}},
+				)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if actorID != "" {
+		_, err := auditLog.UpdateMany(ctx, bson.M{"actor_type": "user", "actor_id": actorID}, bson.M{"$unset": bson.M{"actor_name": ""}})
+		return err
+	}
+	return nil
+}
+
+// recordAudit records a change made by the request. before is nil for
+// creates, after is nil for permanent deletes.
+func recordAudit(c *fiber.Ctx, db *mongo.Client, action, collection string, id primitive.ObjectID, before, after interface{}) {
+	actorType, actorID, actorName := auditActor(c)
+	insertAudit(db, models.AuditEvent{
+		Action:     action,
+		Collection: collection,
+		DocumentID: id,
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		IP:         c.IP(),
+		Changes:    auditDiff(before, after),
+	})
+}
+
+// recordTrashAudit records a document moved to the trash. deletedWith is the
+// document whose cascading delete trashed it, if any.
+func recordTrashAudit(c *fiber.Ctx, db *mongo.Client, collection string, id primitive.ObjectID, deletedWith *primitive.ObjectID) {
+	deletedBy, _ := c.Locals("admin_id").(string)
+	after := bson.M{"deleted_by": deletedBy}
+	if deletedWith != nil {
+		after["deleted_with"] = *deletedWith
+	}
+	recordAudit(c, db, models.AuditActionDelete, collection, id, nil, after)
+}
+
+// auditedUpdate applies update to the document matching filter and records
+// the change. It returns mongo.ErrNoDocuments when nothing matched.
+func auditedUpdate(c *fiber.Ctx, db *mongo.Client, collection string, filter, update bson.M) error {
+	coll := config.GetCollection(db, collection)
+
+	var before bson.M
+	if err := coll.FindOneAndUpdate(context.TODO(), filter, update).Decode(&before); err != nil {
+		return err
+	}
+	id, _ := before["_id"].(primitive.ObjectID)
+
+	var after bson.M
+	if err := coll.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&after); err == nil {
+		recordAudit(c, db, models.AuditActionUpdate, collection, id, before, after)
+	}
+	return nil
+}
+
+// auditFilter builds the audit log filter shared by the list endpoints.
+func auditFilter(c *fiber.Ctx) (bson.M, error) {
+	filter := bson.M{}
+	if action := c.Query("action"); action != "" {
+		filter["action"] = action
+	}
+	if actorID := c.Query("actor_id"); actorID != "" {
+		filter["actor_id"] = actorID
+	}
+	if actorType := c.Query("actor_type"); actorType != "" {
+		filter["actor_type"] = actorType
+	}
+	if field := c.Query("field"); field != "" {
+		filter["changes."+field] = bson.M{"$exists": true}
+	}
+
+	startDate, endDate, err := utils.ParseDateRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		return nil, err
+	}
+	if !startDate.IsZero() || !endDate.IsZero() {
+		createdAt := bson.M{}
+		if !startDate.IsZero() {
+			createdAt["$gte"] = startDate
+		}
+		if !endDate.IsZero() {
+			createdAt["$lt"] = endDate
+		}
+		filter["created_at"] = createdAt
+	}
+	return filter, nil
+}
+
+// listAuditEvents renders one page of audit events matching filter.
+func listAuditEvents(c *fiber.Ctx, db *mongo.Client, filter bson.M) error {
+	page, limit, skip := utils.ParsePaginationParams(c)
+
+	collection := config.GetCollection(db, "audit_log")
+	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return utils.Internal("Failed to fetch audit log")
+	}
+	defer cursor.Close(context.TODO())
+
+	// Decoded as maps so nested before/after values render as JSON objects
+	events := []bson.M{}
+	if err = cursor.All(context.TODO(), &events); err != nil {
+		return utils.Internal("Failed to decode audit log")
+	}
+
+	total, _ := collection.CountDocuments(context.TODO(), filter)
+
+	return c.JSON(utils.PaginationResponse(events, total, page, limit))
+}
+
+// AuditLogRoutes exposes the change history recorded for every mutation.
+func AuditLogRoutes(app fiber.Router, db *mongo.Client) {
+	audit := app.Group("/audit-log")
+
+	ensureAuditIndexes(db)
+
+	// Browse the whole audit log (superadmin only)
+	audit.Get("/", middleware.AdminJWTMiddleware(superAdminRoles...), func(c *fiber.Ctx) error {
+		filter, err := auditFilter(c)
+		if err != nil {
+			return utils.BadRequest(err.Error())
+		}
+		if collection := c.Query("collection"); collection != "" {
+			filter["collection"] = collection
+		}
+		if documentID := c.Query("document_id"); documentID != "" {
+			id, err := primitive.ObjectIDFromHex(documentID)
+			if err != nil {
+				return utils.BadRequest("Invalid document_id")
+			}
+			filter["document_id"] = id
+		}
+
+		return listAuditEvents(c, db, filter)
+	})
+
+	// History of one document, for admins allowed to manage the resource
+	audit.Get("/:resource/:id", middleware.AdminJWTMiddleware(allAdminRoles...), func(c *fiber.Ctx) error {
+		res, ok := trashResources[c.Params("resource")]
+		if !ok {
+			res, ok = auditResources[c.Params("resource")]
+		}
+		if !ok {
+			return utils.NotFound("Unknown resource")
+		}
+		if !hasAdminRole(c, res.Roles) {
+			return utils.Forbidden("Insufficient permissions")
+		}
+
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.BadRequest("Invalid ID")
+		}
+
+		filter, err := auditFilter(c)
+		if err != nil {
+			return utils.BadRequest(err.Error())
+		}
+		filter["collection"] = res.Collection
+		filter["document_id"] = id
+
+		return listAuditEvents(c, db, filter)
+	})
+}
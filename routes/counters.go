@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// nextSequence atomically increments and returns the named counter, creating
+// it at 1 on first use. A single FindOneAndUpdate keeps concurrent callers
+// from ever receiving the same value.
+func nextSequence(db *mongo.Client, name string) (int64, error) {
+	collection := config.GetCollection(db, "counters")
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter models.Counter
+	err := collection.FindOneAndUpdate(context.TODO(),
+		bson.M{"_id": name},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		opts,
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// nextYearlyNumber returns the next number of a sequence that restarts every
+// year, formatted as PREFIX-YEAR-000123 with the given zero padding.
+func nextYearlyNumber(db *mongo.Client, prefix string, width int, at time.Time) (string, error) {
+	year := at.Year()
+	seq, err := nextSequence(db, fmt.Sprintf("%s-%d", prefix, year))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%d-%0*d", prefix, year, width, seq), nil
+}
+
+// nextOrderNumber returns a number like ORD-2026-000123.
+func nextOrderNumber(db *mongo.Client, at time.Time) (string, error) {
+	return nextYearlyNumber(db, "ORD", 6, at)
+}
+
+// nextContractNumber returns a number like CTR-2026-0042.
+func nextContractNumber(db *mongo.Client, at time.Time) (string, error) {
+	return nextYearlyNumber(db, "CTR", 4, at)
+}
@@ -0,0 +1,301 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// historyParty describes a CRM collection that carries a denormalized
+// order_history with order_count, total_amount and per-currency totals.
+type historyParty struct {
+	Collection    string
+	ContractField string
+	// OrderField links orders to the party; empty when orders do not reference it.
+	OrderField string
+}
+
+// orderCurrency is the currency order totals are priced in.
+const orderCurrency = "UZS"
+
+var historyParties = map[string]historyParty{
+	"company":      {Collection: "companies", ContractField: "company_id"},
+	"client":       {Collection: "clients", ContractField: "client_id", OrderField: "client_id"},
+	"counterparty": {Collection: "counterparties", ContractField: "counterparty_id"},
+}
+
+// rebuildPartyHistory recomputes the order history and aggregates of one
+// company, client or counterparty from the contracts and orders that
+// reference it. The reads and the write run in one transaction: when two
+// rebuilds of the same party overlap, the one that read an older snapshot
+// hits a write conflict and is retried against the newer data instead of
+// overwriting it. Standalone servers have no transactions, so there the last
+// rebuild to finish wins; POST /crm/rebuild-history repairs the result.
+func rebuildPartyHistory(db *mongo.Client, kind string, id primitive.ObjectID) error {
+	return runInTransaction(db, func(ctx context.Context) error {
+		return storePartyHistory(ctx, db, kind, id)
+	})
+}
+
+// storePartyHistory reads and writes within ctx; see rebuildPartyHistory.
+func storePartyHistory(ctx context.Context, db *mongo.Client, kind string, id primitive.ObjectID) error {
+	party := historyParties[kind]
+
+	var contracts []models.Contract
+	cursor, err := config.GetCollection(db, "contracts").Find(ctx, notTrashed(bson.M{party.ContractField: id}))
+	if err != nil {
+		return err
+	}
+	if err := cursor.All(ctx, &contracts); err != nil {
+		return err
+	}
+
+	var orders []models.Order
+	if party.OrderField != "" {
+		cursor, err := config.GetCollection(db, "orders").Find(ctx, notTrashed(bson.M{party.OrderField: id}))
+		if err != nil {
+			return err
+		}
+		if err := cursor.All(ctx, &orders); err != nil {
+			return err
+		}
+	}
+
+	productNames, funnelNames := contractLookups(ctx, db, contracts)
+
+	history := make([]models.OrderHistoryEntry, 0, len(contracts)+len(orders))
+	for _, ct := range contracts {
+		products := make([]models.OrderHistoryProduct, 0, len(ct.Products))
+		for _, p := range ct.Products {
+			products = append(products, models.OrderHistoryProduct{
+				ID:           p.ProductID.Hex(),
+				Name:         productNames[p.ProductID],
+				Price:        p.Price,
+				Quantity:     p.Quantity,
+				SerialNumber: p.SerialNumber,
+				ShtrixNumber: p.ShtrixNumber,
+				CreatedAt:    ct.CreatedAt,
+				UpdatedAt:    ct.UpdatedAt,
+			})
+		}
+		history = append(history, models.OrderHistoryEntry{
+			ID:          ct.ID.Hex(),
+			OrderNumber: ct.ContractNumber,
+			Price:       ct.ContractAmount,
+			Currency:    ct.ContractCurrency,
+			Status:      funnelNames[ct.FunnelID],
+			CreatedAt:   ct.CreatedAt,
+			UpdatedAt:   ct.UpdatedAt,
+			Products:    products,
+		})
+	}
+	for _, o := range orders {
+		products := make([]models.OrderHistoryProduct, 0, len(o.ProductsWithCount))
+		for _, line := range o.ProductsWithCount {
+			products = append(products, models.OrderHistoryProduct{
+				ID:        line.ProductID.Hex(),
+				Name:      line.Name,
+				Price:     models.NewFlexFloat64(line.UnitPrice),
+				Quantity:  line.Count,
+				CreatedAt: o.CreatedAt,
+				UpdatedAt: o.UpdatedAt,
+			})
+		}
+		history = append(history, models.OrderHistoryEntry{
+			ID:          o.ID.Hex(),
+			OrderNumber: o.OrderNumber,
+			Price:       models.NewFlexFloat64(o.TotalAmount),
+			Currency:    orderCurrency,
+			Status:      o.CurrentStatus(),
+			CreatedAt:   o.CreatedAt,
+			UpdatedAt:   o.UpdatedAt,
+			Products:    products,
+		})
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].CreatedAt.After(history[j].CreatedAt)
+	})
+
+	// Cancelled orders stay in the history but do not count towards the totals.
+	// Contracts are priced in different currencies, so totals breaks
+	// total_amount down per currency.
+	orderCount, totalAmount := 0, 0.0
+	totals := map[string]float64{}
+	for _, entry := range history {
+		if entry.Status == models.OrderStatusCancelled {
+			continue
+		}
+		orderCount++
+		totalAmount += entry.Price.Float64()
+		totals[entry.Currency] += entry.Price.Float64()
+	}
+	for currency, amount := range totals {
+		totals[currency] = roundMoney(amount)
+	}
+
+	_, err = config.GetCollection(db, party.Collection).UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"order_history": history,
+		"order_count":   orderCount,
+		"total_amount":  roundMoney(totalAmount),
+		"totals":        totals,
+	}})
+	return err
+}
+
+// contractLookups resolves the product and funnel names referenced by contracts.
+func contractLookups(ctx context.Context, db *mongo.Client, contracts []models.Contract) (map[primitive.ObjectID]string, map[primitive.ObjectID]string) {
+	productNames := map[primitive.ObjectID]string{}
+	funnelNames := map[primitive.ObjectID]string{}
+
+	var productIDs, funnelIDs []primitive.ObjectID
+	for _, ct := range contracts {
+		if !ct.FunnelID.IsZero() {
+			funnelIDs = append(funnelIDs, ct.FunnelID)
+		}
+		for _, p := range ct.Products {
+			productIDs = append(productIDs, p.ProductID)
+		}
+	}
+
+	if len(productIDs) > 0 {
+		var products []models.Product
+		if cursor, err := config.GetCollection(db, "products").Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}}); err == nil {
+			cursor.All(ctx, &products)
+		}
+		for _, p := range products {
+			productNames[p.ID] = p.Name
+		}
+	}
+	if len(funnelIDs) > 0 {
+		var funnels []models.Funnel
+		if cursor, err := config.GetCollection(db, "funnels").Find(ctx, bson.M{"_id": bson.M{"$in": funnelIDs}}); err == nil {
+			cursor.All(ctx, &funnels)
+		}
+		for _, f := range funnels {
+			funnelNames[f.ID] = f.Name
+		}
+	}
+
+	return productNames, funnelNames
+}
+
+// syncParty rebuilds one party's history, logging failures; the rebuild
+// endpoint repairs anything a failed sync leaves behind.
+func syncParty(db *mongo.Client, kind string, id primitive.ObjectID) {
+	if err := rebuildPartyHistory(db, kind, id); err != nil {
+		log.Printf("Failed to sync %s %s order history: %v", kind, id.Hex(), err)
+	}
+}
+
+// syncContractParties refreshes the company, client and counterparty of every
+// given contract. Pass both the old and new version when a contract changes so
+// parties it was moved away from are refreshed too.
+func syncContractParties(db *mongo.Client, contracts ...models.Contract) {
+	seen := map[string]bool{}
+	for _, ct := range contracts {
+		refs := map[string]primitive.ObjectID{
+			"company":      ct.CompanyID,
+			"client":       ct.ClientID,
+			"counterparty": ct.CounterpartyID,
+		}
+		for kind, id := range refs {
+			if id.IsZero() || seen[kind+id.Hex()] {
+				continue
+			}
+			seen[kind+id.Hex()] = true
+			syncParty(db, kind, id)
+		}
+	}
+}
+
+// syncOrderClients refreshes the clients referenced by the given orders.
+func syncOrderClients(db *mongo.Client, orders ...models.Order) {
+	seen := map[primitive.ObjectID]bool{}
+	for _, o := range orders {
+		if o.ClientID == nil || o.ClientID.IsZero() || seen[*o.ClientID] {
+			continue
+		}
+		seen[*o.ClientID] = true
+		syncParty(db, "client", *o.ClientID)
+	}
+}
+
+// CRMHistoryRoutes exposes maintenance endpoints for the denormalized CRM history.
+func CRMHistoryRoutes(app fiber.Router, db *mongo.Client) {
+	crm := app.Group("/crm", middleware.AdminJWTMiddleware(salesRoles...))
+
+	// Rebuild order_history, order_count, total_amount and totals from contracts and orders.
+	// Without parameters every company, client and counterparty is rebuilt;
+	// ?entity=company|client|counterparty limits the kind and &id= a single record.
+	crm.Post("/rebuild-history", func(c *fiber.Ctx) error {
+		entity := c.Query("entity")
+		kinds := []string{"company", "client", "counterparty"}
+		if entity != "" {
+			if _, ok := historyParties[entity]; !ok {
+				return utils.BadRequest("entity must be one of company, client, counterparty")
+			}
+			kinds = []string{entity}
+		}
+
+		if idStr := c.Query("id"); idStr != "" {
+			if entity == "" {
+				return utils.BadRequest("entity is required when id is given")
+			}
+			id, err := primitive.ObjectIDFromHex(idStr)
+			if err != nil {
+				return utils.BadRequest("Invalid ID")
+			}
+			if n, _ := config.GetCollection(db, historyParties[entity].Collection).CountDocuments(context.TODO(), notTrashed(bson.M{"_id": id})); n == 0 {
+				return utils.NotFound("Record not found")
+			}
+			if err := rebuildPartyHistory(db, entity, id); err != nil {
+				return utils.Internal("Failed to rebuild history")
+			}
+			return c.JSON(fiber.Map{"message": "History rebuilt", "rebuilt": fiber.Map{entity: 1}})
+		}
+
+		rebuilt := fiber.Map{}
+		failed := 0
+		for _, kind := range kinds {
+			cursor, err := config.GetCollection(db, historyParties[kind].Collection).Find(context.TODO(), notTrashed(bson.M{}))
+			if err != nil {
+				return utils.Internal("Failed to fetch " + historyParties[kind].Collection)
+			}
+
+			count := 0
+			for cursor.Next(context.TODO()) {
+				var doc struct {
+					ID primitive.ObjectID `bson:"_id"`
+				}
+				if cursor.Decode(&doc) != nil {
+					continue
+				}
+				if err := rebuildPartyHistory(db, kind, doc.ID); err != nil {
+					log.Printf("Failed to rebuild %s %s order history: %v", kind, doc.ID.Hex(), err)
+					failed++
+					continue
+				}
+				count++
+			}
+			cursor.Close(context.TODO())
+			rebuilt[kind] = count
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "History rebuilt",
+			"rebuilt": rebuilt,
+			"failed":  failed,
+		})
+	})
+}
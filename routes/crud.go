@@ -2,11 +2,14 @@ package routes
 
 import (
 	"context"
-	"strconv"
 	"time"
 
 	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
 	"fiber-ecommerce/models"
+	"fiber-ecommerce/query"
+	"fiber-ecommerce/utils"
+	"fiber-ecommerce/validation"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,51 +21,50 @@ import (
 // Reviews CRUD
 func ReviewRoutes(app fiber.Router, db *mongo.Client) {
 	reviews := app.Group("/reviews")
+	registerTrash("reviews", "reviews", catalogRoles)
 
 	// Get all reviews
 	reviews.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "reviews")
 
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		skip := (page - 1) * limit
-
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+		list, err := reviewQuery.Parse(c)
+		if err != nil {
+			return err
+		}
+		filter := list.Apply(notTrashed(bson.M{}))
 
-		cursor, err := collection.Find(context.TODO(), bson.M{}, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+			return utils.Internal("Failed to fetch reviews")
 		}
 		defer cursor.Close(context.TODO())
 
 		var reviews []models.Reviews
 		if err = cursor.All(context.TODO(), &reviews); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode reviews"})
+			return utils.Internal("Failed to decode reviews")
 		}
+		reviews, next := query.Page(list, reviews)
 
-		// Get total count
-		total, _ := collection.CountDocuments(context.TODO(), bson.M{})
+		data, err := list.Select(reviews)
+		if err != nil {
+			return utils.Internal("Failed to encode reviews")
+		}
 
-		return c.JSON(fiber.Map{
-			"data":  reviews,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(list.Response(data, next, collection, filter))
 	})
 
 	// Get single review
 	reviews.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "reviews")
 		var review models.Reviews
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&review)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&review)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Review not found"})
+			return utils.NotFound("Review not found")
 		}
 
 		return c.JSON(review)
@@ -71,8 +73,8 @@ func ReviewRoutes(app fiber.Router, db *mongo.Client) {
 	// Create review
 	reviews.Post("/", func(c *fiber.Ctx) error {
 		var review models.Reviews
-		if err := c.BodyParser(&review); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &review); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		review.CreatedAt = time.Now()
@@ -80,92 +82,85 @@ func ReviewRoutes(app fiber.Router, db *mongo.Client) {
 
 		result, err := collection.InsertOne(context.TODO(), review)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create review"})
+			return utils.Internal("Failed to create review")
 		}
 
 		review.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "reviews", review.ID, nil, review)
 		return c.Status(201).JSON(review)
 	})
 
 	// Update review
-	reviews.Put("/:id", func(c *fiber.Ctx) error {
+	reviews.Put("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		updateData, errs := validation.Decode(c.Body(), models.Reviews{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		delete(updateData, "_id")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "reviews")
 		update := bson.M{"$set": updateData}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update review"})
+		err = auditedUpdate(c, db, "reviews", notTrashed(bson.M{"_id": id}), update)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Review not found")
 		}
-
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Review not found"})
+		if err != nil {
+			return utils.Internal("Failed to update review")
 		}
 
 		var review models.Reviews
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&review)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&review)
 		return c.JSON(review)
 	})
 
 	// Delete review
-	reviews.Delete("/:id", func(c *fiber.Ctx) error {
+	reviews.Delete("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, "reviews")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		report, err := safeDelete(c, db, "reviews", id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete review"})
-		}
-
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Review not found"})
+			return deleteError(err, report, "Review not found", "Failed to delete review")
 		}
 
-		return c.JSON(fiber.Map{"message": "Review deleted successfully"})
+		return c.JSON(deleteResult("Review deleted successfully", report))
 	})
 }
 
 // TopCategory CRUD
 func TopCategoryRoutes(app fiber.Router, db *mongo.Client) {
 	topCategories := app.Group("/top-categories")
+	registerTrash("top-categories", "topcategories", catalogRoles)
 
 	// Get all top categories
 	topCategories.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "topcategories")
 
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		skip := (page - 1) * limit
+		page, limit, skip := utils.ParsePaginationParams(c)
 
 		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
 
-		cursor, err := collection.Find(context.TODO(), bson.M{}, opts)
+		cursor, err := collection.Find(context.TODO(), notTrashed(bson.M{}), opts)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch top categories"})
+			return utils.Internal("Failed to fetch top categories")
 		}
 		defer cursor.Close(context.TODO())
 
 		var topCategories []models.TopCategory
 		if err = cursor.All(context.TODO(), &topCategories); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode top categories"})
+			return utils.Internal("Failed to decode top categories")
 		}
 
-		total, _ := collection.CountDocuments(context.TODO(), bson.M{})
+		total, _ := collection.CountDocuments(context.TODO(), notTrashed(bson.M{}))
 
 		return c.JSON(fiber.Map{
 			"data":  topCategories,
@@ -179,24 +174,24 @@ func TopCategoryRoutes(app fiber.Router, db *mongo.Client) {
 	topCategories.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "topcategories")
 		var topCategory models.TopCategory
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&topCategory)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&topCategory)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Top category not found"})
+			return utils.NotFound("Top category not found")
 		}
 
 		return c.JSON(topCategory)
 	})
 
 	// Create top category
-	topCategories.Post("/", func(c *fiber.Ctx) error {
+	topCategories.Post("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		var topCategory models.TopCategory
-		if err := c.BodyParser(&topCategory); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &topCategory); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		topCategory.CreatedAt = time.Now()
@@ -205,79 +200,72 @@ func TopCategoryRoutes(app fiber.Router, db *mongo.Client) {
 
 		result, err := collection.InsertOne(context.TODO(), topCategory)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create top category"})
+			return utils.Internal("Failed to create top category")
 		}
 
 		topCategory.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "topcategories", topCategory.ID, nil, topCategory)
 		return c.Status(201).JSON(topCategory)
 	})
 
 	// Update top category
-	topCategories.Put("/:id", func(c *fiber.Ctx) error {
+	topCategories.Put("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		updateData, errs := validation.Decode(c.Body(), models.TopCategory{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		delete(updateData, "_id")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "topcategories")
 		update := bson.M{"$set": updateData}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update top category"})
+		err = auditedUpdate(c, db, "topcategories", notTrashed(bson.M{"_id": id}), update)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Top category not found")
 		}
-
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Top category not found"})
+		if err != nil {
+			return utils.Internal("Failed to update top category")
 		}
 
 		var topCategory models.TopCategory
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&topCategory)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&topCategory)
 		return c.JSON(topCategory)
 	})
 
 	// Delete top category
-	topCategories.Delete("/:id", func(c *fiber.Ctx) error {
+	topCategories.Delete("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, "topcategories")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		report, err := safeDelete(c, db, "topcategories", id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete top category"})
+			return deleteError(err, report, "Top category not found", "Failed to delete top category")
 		}
 
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Top category not found"})
-		}
-
-		return c.JSON(fiber.Map{"message": "Top category deleted successfully"})
+		return c.JSON(deleteResult("Top category deleted successfully", report))
 	})
 }
 
 // Category CRUD
 func CategoryRoutes(app fiber.Router, db *mongo.Client) {
 	categories := app.Group("/categories")
+	registerTrash("categories", "categories", catalogRoles)
 
 	// Get all categories
 	categories.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "categories")
 
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		skip := (page - 1) * limit
+		page, limit, skip := utils.ParsePaginationParams(c)
 
-		filter := bson.M{}
+		filter := notTrashed(bson.M{})
 		if topCategoryID := c.Query("top_category_id"); topCategoryID != "" {
 			if id, err := primitive.ObjectIDFromHex(topCategoryID); err == nil {
 				filter["top_category_id"] = id
@@ -288,13 +276,13 @@ func CategoryRoutes(app fiber.Router, db *mongo.Client) {
 
 		cursor, err := collection.Find(context.TODO(), filter, opts)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch categories"})
+			return utils.Internal("Failed to fetch categories")
 		}
 		defer cursor.Close(context.TODO())
 
 		var categories []models.Category
 		if err = cursor.All(context.TODO(), &categories); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode categories"})
+			return utils.Internal("Failed to decode categories")
 		}
 
 		total, _ := collection.CountDocuments(context.TODO(), filter)
@@ -323,14 +311,14 @@ func CategoryRoutes(app fiber.Router, db *mongo.Client) {
 	categories.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "categories")
 		var category models.Category
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&category)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&category)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Category not found"})
+			return utils.NotFound("Category not found")
 		}
 
 		// Populate top category name
@@ -347,10 +335,18 @@ func CategoryRoutes(app fiber.Router, db *mongo.Client) {
 	})
 
 	// Create category
-	categories.Post("/", func(c *fiber.Ctx) error {
+	categories.Post("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		var category models.Category
-		if err := c.BodyParser(&category); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &category); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		broken, err := checkReferences(db, referenceChecks(bson.M{"top_category_id": category.TopCategoryID}, categoryReferences)...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
 		}
 
 		category.CreatedAt = time.Now()
@@ -359,86 +355,83 @@ func CategoryRoutes(app fiber.Router, db *mongo.Client) {
 
 		result, err := collection.InsertOne(context.TODO(), category)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create category"})
+			return utils.Internal("Failed to create category")
 		}
 
 		category.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "categories", category.ID, nil, category)
 		return c.Status(201).JSON(category)
 	})
 
 	// Update category
-	categories.Put("/:id", func(c *fiber.Ctx) error {
+	categories.Put("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		updateData, errs := validation.Decode(c.Body(), models.Category{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		delete(updateData, "_id")
 		updateData["updated_at"] = time.Now()
 
+		broken, err := checkReferences(db, referenceChecks(updateData, categoryReferences)...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
+		}
+
 		collection := config.GetCollection(db, "categories")
 		update := bson.M{"$set": updateData}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update category"})
+		err = auditedUpdate(c, db, "categories", notTrashed(bson.M{"_id": id}), update)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Category not found")
 		}
-
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Category not found"})
+		if err != nil {
+			return utils.Internal("Failed to update category")
 		}
 
 		var category models.Category
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&category)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&category)
 		return c.JSON(category)
 	})
 
 	// Delete category
-	categories.Delete("/:id", func(c *fiber.Ctx) error {
+	categories.Delete("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, "categories")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		report, err := safeDelete(c, db, "categories", id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete category"})
-		}
-
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Category not found"})
+			return deleteError(err, report, "Category not found", "Failed to delete category")
 		}
 
-		return c.JSON(fiber.Map{"message": "Category deleted successfully"})
+		return c.JSON(deleteResult("Category deleted successfully", report))
 	})
 }
 
 // Product CRUD
 func ProductRoutes(app fiber.Router, db *mongo.Client) {
 	products := app.Group("/products")
+	registerTrash("products", "products", catalogRoles)
 
 	// Get all products with category names populated
 	products.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "products")
 
-		page := 1
-		if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
-			page = p
-		}
-
-		limit := 10
-		if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
-			limit = l
+		list, err := productQuery.Parse(c)
+		if err != nil {
+			return err
 		}
-		skip := (page - 1) * limit
 
-		filter := bson.M{}
+		filter := notTrashed(bson.M{})
 		if categoryID := c.Query("category_id"); categoryID != "" {
 			if id, err := primitive.ObjectIDFromHex(categoryID); err == nil {
 				filter["category_id"] = id
@@ -464,18 +457,19 @@ func ProductRoutes(app fiber.Router, db *mongo.Client) {
 			}
 		}
 
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+		filter = list.Apply(filter)
 
-		cursor, err := collection.Find(context.TODO(), filter, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch products"})
+			return utils.Internal("Failed to fetch products")
 		}
 		defer cursor.Close(context.TODO())
 
 		var products []models.Product
 		if err = cursor.All(context.TODO(), &products); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode products"})
+			return utils.Internal("Failed to decode products")
 		}
+		products, next := query.Page(list, products)
 
 		// Populate category and top category names
 		for i := range products {
@@ -485,28 +479,26 @@ func ProductRoutes(app fiber.Router, db *mongo.Client) {
 			populateCategoryNames(db, &products[i])
 		}
 
-		total, _ := collection.CountDocuments(context.TODO(), filter)
+		data, err := list.Select(products)
+		if err != nil {
+			return utils.Internal("Failed to encode products")
+		}
 
-		return c.JSON(fiber.Map{
-			"data":  products,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(list.Response(data, next, collection, filter))
 	})
 
 	// Get single product with category names populated
 	products.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "products")
 		var product models.Product
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&product)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&product)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
+			return utils.NotFound("Product not found")
 		}
 
 		// Populate category and top category names
@@ -519,30 +511,26 @@ func ProductRoutes(app fiber.Router, db *mongo.Client) {
 	})
 
 	// Create product (populate top_category_id automatically)
-	products.Post("/", func(c *fiber.Ctx) error {
+	products.Post("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		var product models.Product
-		if err := c.BodyParser(&product); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &product); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		if !product.Price.Valid() {
-			return c.Status(400).JSON(fiber.Map{"error": "price is required"})
-		}
-		if !product.Tax.Valid() {
-			return c.Status(400).JSON(fiber.Map{"error": "tax is required"})
-		}
-		if product.Count < 0 {
-			return c.Status(400).JSON(fiber.Map{"error": "count cannot be negative"})
-		}
-		if product.ShtrixNumber == "" {
-			return c.Status(400).JSON(fiber.Map{"error": "shtrix_number is required"})
-		}
 		if product.Images == nil {
 			product.Images = []string{}
 		}
 
-		product.CategoryName = nil
-		product.TopCategoryName = nil
+		broken, err := checkReferences(db, referenceChecks(bson.M{
+			"category_id":     product.CategoryID,
+			"top_category_id": product.TopCategoryID,
+		}, productReferences)...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
+		}
 
 		// Auto-populate top_category_id from category
 		if product.CategoryID != nil {
@@ -560,10 +548,15 @@ func ProductRoutes(app fiber.Router, db *mongo.Client) {
 
 		result, err := collection.InsertOne(context.TODO(), product)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create product"})
+			return utils.Internal("Failed to create product")
 		}
 
 		product.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "products", product.ID, nil, product)
+
+		actor := adminActor(c)
+		actor.Note = "initial stock"
+		recordStockAdjustment(db, product.ID, product.Count, actor)
 
 		// Populate category names for response
 		populateCategoryNames(db, &product)
@@ -572,107 +565,70 @@ func ProductRoutes(app fiber.Router, db *mongo.Client) {
 	})
 
 	// Update product
-	products.Put("/:id", func(c *fiber.Ctx) error {
+	products.Put("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
-		}
-
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		// Rename JSON fields to match stored BSON field names
-		if images, exists := updateData["images"]; exists {
-			updateData["image"] = images
-			delete(updateData, "images")
+		// Reservations are only changed by the order lifecycle and category
+		// names are derived, so both are read-only here
+		updateData, errs := validation.Decode(c.Body(), models.Product{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
+		newCount, countChanged := updateData["count"].(int)
 
-		// Convert numeric fields to appropriate types
-		if priceVal, ok := updateData["price"]; ok {
-			if val, valid := toFloat64(priceVal); valid {
-				updateData["price"] = val
-			} else {
-				return c.Status(400).JSON(fiber.Map{"error": "price must be numeric"})
-			}
-		}
-		if discountVal, ok := updateData["discount"]; ok {
-			if discountVal == nil {
-				updateData["discount"] = nil
-			} else if val, valid := toFloat64(discountVal); valid {
-				updateData["discount"] = val
-			} else {
-				return c.Status(400).JSON(fiber.Map{"error": "discount must be numeric"})
-			}
-		}
-		if ndcVal, ok := updateData["NDC"]; ok {
-			if ndcVal == nil {
-				updateData["NDC"] = nil
-			} else if val, valid := toFloat64(ndcVal); valid {
-				updateData["NDC"] = val
-			} else {
-				return c.Status(400).JSON(fiber.Map{"error": "NDC must be numeric"})
-			}
+		broken, err := checkReferences(db, referenceChecks(updateData, productReferences)...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
 		}
-		if taxVal, ok := updateData["tax"]; ok {
-			if val, valid := toFloat64(taxVal); valid {
-				updateData["tax"] = val
-			} else {
-				return c.Status(400).JSON(fiber.Map{"error": "tax must be numeric"})
-			}
-		}
-		if countVal, ok := updateData["count"]; ok {
-			if val, valid := toInt(countVal); valid {
-				updateData["count"] = val
-			} else {
-				return c.Status(400).JSON(fiber.Map{"error": "count must be numeric"})
-			}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
 		}
 
 		// Auto-populate top_category_id if category_id is being updated
-		if categoryIDInterface, exists := updateData["category_id"]; exists {
-			if categoryIDStr, ok := categoryIDInterface.(string); ok {
-				if categoryID, err := primitive.ObjectIDFromHex(categoryIDStr); err == nil {
-					updateData["category_id"] = categoryID
-					categoryCollection := config.GetCollection(db, "categories")
-					var category models.Category
-					err := categoryCollection.FindOne(context.TODO(), bson.M{"_id": categoryID}).Decode(&category)
-					if err == nil && category.TopCategoryID != nil {
-						updateData["top_category_id"] = category.TopCategoryID
-					}
-				}
-			}
-		}
-
-		if topCategoryIDInterface, exists := updateData["top_category_id"]; exists {
-			if topCategoryStr, ok := topCategoryIDInterface.(string); ok {
-				if topCategoryID, err := primitive.ObjectIDFromHex(topCategoryStr); err == nil {
-					updateData["top_category_id"] = topCategoryID
-				}
+		if categoryID, ok := updateData["category_id"].(*primitive.ObjectID); ok && categoryID != nil {
+			categoryCollection := config.GetCollection(db, "categories")
+			var category models.Category
+			err := categoryCollection.FindOne(context.TODO(), bson.M{"_id": categoryID}).Decode(&category)
+			if err == nil && category.TopCategoryID != nil {
+				updateData["top_category_id"] = category.TopCategoryID
 			}
 		}
 
-		delete(updateData, "category_name")
-		delete(updateData, "top_category_name")
-		delete(updateData, "_id")
-		delete(updateData, "created_at")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "products")
 		update := bson.M{"$set": updateData}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
+		// The count cannot drop below what confirmed orders have reserved
+		filter := notTrashed(bson.M{"_id": id})
+		if countChanged {
+			filter["$expr"] = bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$reserved", 0}}, newCount}}
+		}
+
+		var before models.Product
+		err = collection.FindOneAndUpdate(context.TODO(), filter, update).Decode(&before)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update product"})
+			if err != mongo.ErrNoDocuments {
+				return utils.Internal("Failed to update product")
+			}
+			if countChanged {
+				if n, _ := collection.CountDocuments(context.TODO(), notTrashed(bson.M{"_id": id})); n > 0 {
+					return utils.Conflict("count cannot be lower than the quantity reserved by confirmed orders")
+				}
+			}
+			return utils.NotFound("Product not found")
 		}
 
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
+		if countChanged {
+			recordStockAdjustment(db, id, newCount-before.Count, adminActor(c))
 		}
 
 		var product models.Product
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&product)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&product)
+		recordAudit(c, db, models.AuditActionUpdate, "products", id, before, product)
 
 		// Populate category names for response
 		populateCategoryNames(db, &product)
@@ -684,51 +640,48 @@ func ProductRoutes(app fiber.Router, db *mongo.Client) {
 	})
 
 	// Delete product
-	products.Delete("/:id", func(c *fiber.Ctx) error {
+	products.Delete("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, "products")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		report, err := safeDelete(c, db, "products", id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete product"})
+			return deleteError(err, report, "Product not found", "Failed to delete product")
 		}
 
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
-		}
-
-		return c.JSON(fiber.Map{"message": "Product deleted successfully"})
+		return c.JSON(deleteResult("Product deleted successfully", report))
 	})
 
 	// Get products by top category
 	products.Get("/by-top-category/:top_category_id", func(c *fiber.Ctx) error {
 		topCategoryID, err := primitive.ObjectIDFromHex(c.Params("top_category_id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid top category ID"})
+			return utils.BadRequest("Invalid top category ID")
 		}
 
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		skip := (page - 1) * limit
+		list, err := productQuery.Parse(c)
+		if err != nil {
+			return err
+		}
 
 		collection := config.GetCollection(db, "products")
-		filter := bson.M{"top_category_id": topCategoryID}
+		filter := notTrashed(bson.M{"top_category_id": topCategoryID})
 
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+		filter = list.Apply(filter)
 
-		cursor, err := collection.Find(context.TODO(), filter, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch products"})
+			return utils.Internal("Failed to fetch products")
 		}
 		defer cursor.Close(context.TODO())
 
 		var products []models.Product
 		if err = cursor.All(context.TODO(), &products); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode products"})
+			return utils.Internal("Failed to decode products")
 		}
+		products, next := query.Page(list, products)
 
 		// Populate category names
 		for i := range products {
@@ -738,43 +691,75 @@ func ProductRoutes(app fiber.Router, db *mongo.Client) {
 			populateCategoryNames(db, &products[i])
 		}
 
+		data, err := list.Select(products)
+		if err != nil {
+			return utils.Internal("Failed to encode products")
+		}
+
+		return c.JSON(list.Response(data, next, collection, filter))
+	})
+
+	// Get the stock ledger of a product
+	products.Get("/:id/stock-movements", middleware.AdminJWTMiddleware(allAdminRoles...), func(c *fiber.Ctx) error {
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.BadRequest("Invalid ID")
+		}
+
+		page, limit, skip := utils.ParsePaginationParams(c)
+		filter := bson.M{"product_id": id}
+		if movementType := c.Query("type"); movementType != "" {
+			filter["type"] = movementType
+		}
+
+		collection := config.GetCollection(db, "stock_movements")
+		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+		cursor, err := collection.Find(context.TODO(), filter, opts)
+		if err != nil {
+			return utils.Internal("Failed to fetch stock movements")
+		}
+		defer cursor.Close(context.TODO())
+
+		movements := []models.StockMovement{}
+		if err = cursor.All(context.TODO(), &movements); err != nil {
+			return utils.Internal("Failed to decode stock movements")
+		}
+
 		total, _ := collection.CountDocuments(context.TODO(), filter)
 
-		return c.JSON(fiber.Map{
-			"data":  products,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(utils.PaginationResponse(movements, total, page, limit))
 	})
 
 	// Get discounted products
 	products.Get("/discounted", func(c *fiber.Ctx) error {
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		skip := (page - 1) * limit
+		list, err := productQuery.Parse(c)
+		if err != nil {
+			return err
+		}
 
 		collection := config.GetCollection(db, "products")
-		filter := bson.M{
+		filter := notTrashed(bson.M{
 			"discount": bson.M{
 				"$ne":     nil,
 				"$exists": true,
 				// "$ne":     "",
 			},
-		}
+		})
 
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+		filter = list.Apply(filter)
 
-		cursor, err := collection.Find(context.TODO(), filter, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch discounted products"})
+			return utils.Internal("Failed to fetch discounted products")
 		}
 		defer cursor.Close(context.TODO())
 
 		var products []models.Product
 		if err = cursor.All(context.TODO(), &products); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode products"})
+			return utils.Internal("Failed to decode products")
 		}
+		products, next := query.Page(list, products)
 
 		// Populate category names
 		for i := range products {
@@ -784,14 +769,12 @@ func ProductRoutes(app fiber.Router, db *mongo.Client) {
 			populateCategoryNames(db, &products[i])
 		}
 
-		total, _ := collection.CountDocuments(context.TODO(), filter)
+		data, err := list.Select(products)
+		if err != nil {
+			return utils.Internal("Failed to encode products")
+		}
 
-		return c.JSON(fiber.Map{
-			"data":  products,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(list.Response(data, next, collection, filter))
 	})
 }
 
@@ -6,7 +6,9 @@ import (
 	"path/filepath"
 	"strings"
 
+	"fiber-ecommerce/middleware"
 	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -21,38 +23,29 @@ func FileRoutes(app fiber.Router, db *mongo.Client) {
 	files := app.Group("/files")
 
 	// Upload single file
-	files.Post("/upload", func(c *fiber.Ctx) error {
+	files.Post("/upload", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		// Check content length first
 		if c.Request().Header.ContentLength() > MaxFileSize {
-			return c.Status(413).JSON(fiber.Map{
-				"error": fmt.Sprintf("File too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024)),
-				"max_size_mb": MaxFileSize / (1024 * 1024),
-				"received_size_mb": c.Request().Header.ContentLength() / (1024 * 1024),
-			})
+			return utils.PayloadTooLarge(fmt.Sprintf("File too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024))).
+				With("max_size_mb", MaxFileSize/(1024*1024)).
+				With("received_size_mb", c.Request().Header.ContentLength()/(1024*1024))
 		}
 
 		file, err := c.FormFile("file")
 		if err != nil {
 			// Provide more specific error messages
 			if strings.Contains(err.Error(), "request body too large") || strings.Contains(err.Error(), "too large") {
-				return c.Status(413).JSON(fiber.Map{
-					"error": fmt.Sprintf("File too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024)),
-					"max_size_mb": MaxFileSize / (1024 * 1024),
-				})
+				return utils.PayloadTooLarge(fmt.Sprintf("File too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024))).
+					With("max_size_mb", MaxFileSize/(1024*1024))
 			}
-			return c.Status(400).JSON(fiber.Map{
-				"error": "Failed to parse uploaded file",
-				"details": err.Error(),
-			})
+			return utils.BadRequest("Failed to parse uploaded file").With("details", err.Error())
 		}
 
 		// Additional file size check
 		if file.Size > MaxFileSize {
-			return c.Status(413).JSON(fiber.Map{
-				"error": fmt.Sprintf("File too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024)),
-				"max_size_mb": MaxFileSize / (1024 * 1024),
-				"file_size_mb": file.Size / (1024 * 1024),
-			})
+			return utils.PayloadTooLarge(fmt.Sprintf("File too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024))).
+				With("max_size_mb", MaxFileSize/(1024*1024)).
+				With("file_size_mb", file.Size/(1024*1024))
 		}
 
 		// Validate file type
@@ -67,11 +60,9 @@ func FileRoutes(app fiber.Router, db *mongo.Client) {
 
 		ext := strings.ToLower(filepath.Ext(file.Filename))
 		if !allowedTypes[ext] {
-			return c.Status(400).JSON(fiber.Map{
-				"error": "Invalid file type",
-				"allowed_types": []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg"},
-				"received_type": ext,
-			})
+			return utils.BadRequest("Invalid file type").
+				With("allowed_types", []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg"}).
+				With("received_type", ext)
 		}
 
 		// Generate unique filename
@@ -80,18 +71,12 @@ func FileRoutes(app fiber.Router, db *mongo.Client) {
 
 		// Create uploads directory if it doesn't exist
 		if err := os.MkdirAll("uploads", 0755); err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to create upload directory",
-				"details": err.Error(),
-			})
+			return utils.Internal("Failed to create upload directory").With("details", err.Error())
 		}
 
 		// Save file
 		if err := c.SaveFile(file, uploadPath); err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to save file",
-				"details": err.Error(),
-			})
+			return utils.Internal("Failed to save file").With("details", err.Error())
 		}
 
 		// Return file URL
@@ -105,37 +90,28 @@ func FileRoutes(app fiber.Router, db *mongo.Client) {
 	})
 
 	// Upload multiple files
-	files.Post("/upload-multiple", func(c *fiber.Ctx) error {
+	files.Post("/upload-multiple", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		// Check content length first
 		if c.Request().Header.ContentLength() > MaxFileSize {
-			return c.Status(413).JSON(fiber.Map{
-				"error": fmt.Sprintf("Total upload size too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024)),
-				"max_size_mb": MaxFileSize / (1024 * 1024),
-				"received_size_mb": c.Request().Header.ContentLength() / (1024 * 1024),
-			})
+			return utils.PayloadTooLarge(fmt.Sprintf("Total upload size too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024))).
+				With("max_size_mb", MaxFileSize/(1024*1024)).
+				With("received_size_mb", c.Request().Header.ContentLength()/(1024*1024))
 		}
 
 		form, err := c.MultipartForm()
 		if err != nil {
 			// Provide more specific error messages
 			if strings.Contains(err.Error(), "request body too large") || strings.Contains(err.Error(), "too large") {
-				return c.Status(413).JSON(fiber.Map{
-					"error": fmt.Sprintf("Upload too large. Maximum total size allowed is %d MB", MaxFileSize/(1024*1024)),
-					"max_size_mb": MaxFileSize / (1024 * 1024),
-				})
+				return utils.PayloadTooLarge(fmt.Sprintf("Upload too large. Maximum total size allowed is %d MB", MaxFileSize/(1024*1024))).
+					With("max_size_mb", MaxFileSize/(1024*1024))
 			}
-			return c.Status(400).JSON(fiber.Map{
-				"error": "Failed to parse multipart form",
-				"details": err.Error(),
-			})
+			return utils.BadRequest("Failed to parse multipart form").With("details", err.Error())
 		}
 
 		files := form.File["files"]
 		if len(files) == 0 {
-			return c.Status(400).JSON(fiber.Map{
-				"error": "No files uploaded",
-				"hint": "Make sure to use 'files' as the form field name for multiple file uploads",
-			})
+			return utils.BadRequest("No files uploaded").
+				With("hint", "Make sure to use 'files' as the form field name for multiple file uploads")
 		}
 
 		var uploadedFiles []models.FileUploadResponse
@@ -157,19 +133,14 @@ func FileRoutes(app fiber.Router, db *mongo.Client) {
 		}
 
 		if totalSize > MaxFileSize {
-			return c.Status(413).JSON(fiber.Map{
-				"error": fmt.Sprintf("Total upload size too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024)),
-				"max_size_mb": MaxFileSize / (1024 * 1024),
-				"total_size_mb": totalSize / (1024 * 1024),
-			})
+			return utils.PayloadTooLarge(fmt.Sprintf("Total upload size too large. Maximum size allowed is %d MB", MaxFileSize/(1024*1024))).
+				With("max_size_mb", MaxFileSize/(1024*1024)).
+				With("total_size_mb", totalSize/(1024*1024))
 		}
 
 		// Create uploads directory if it doesn't exist
 		if err := os.MkdirAll("uploads", 0755); err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to create upload directory",
-				"details": err.Error(),
-			})
+			return utils.Internal("Failed to create upload directory").With("details", err.Error())
 		}
 
 		for i, file := range files {
@@ -219,12 +190,10 @@ func FileRoutes(app fiber.Router, db *mongo.Client) {
 		}
 
 		if len(uploadedFiles) == 0 {
-			return c.Status(400).JSON(fiber.Map{
-				"error": "No valid files were uploaded",
-				"failed_files": failedFiles,
-				"allowed_types": []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg"},
-				"max_size_mb": MaxFileSize / (1024 * 1024),
-			})
+			return utils.BadRequest("No valid files were uploaded").
+				With("failed_files", failedFiles).
+				With("allowed_types", []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg"}).
+				With("max_size_mb", MaxFileSize/(1024*1024))
 		}
 
 		response := fiber.Map{
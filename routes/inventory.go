@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// stockError reports order lines whose stock could not be moved.
+type stockError struct {
+	Problems []orderLineProblem
+}
+
+func (e *stockError) Error() string {
+	return "insufficient stock"
+}
+
+// availableStockExpr computes count - reserved inside an aggregation expression.
+var availableStockExpr = bson.M{"$subtract": bson.A{
+	bson.M{"$ifNull": bson.A{"$count", 0}},
+	bson.M{"$ifNull": bson.A{"$reserved", 0}},
+}}
+
+// availableAtLeast matches products with at least n units neither shipped nor reserved.
+func availableAtLeast(n int) bson.M {
+	return bson.M{"$gte": bson.A{availableStockExpr, n}}
+}
+
+// moveStock applies countMul*n to count and reservedMul*n to reserved for every
+// line of the order. Each update is guarded so stock can never go negative or
+// be reserved twice, which keeps concurrent orders from overselling without
+// needing a replica set for transactions. If any line fails, the lines already
+// moved are rolled back and a *stockError is returned. Successful moves are
+// written to the stock_movements ledger.
+func moveStock(db *mongo.Client, order models.Order, movementType string, countMul, reservedMul int, actor models.OrderStatusChange) error {
+	collection := config.GetCollection(db, "products")
+
+	for i, line := range order.ProductsWithCount {
+		n := line.Count
+		filter := bson.M{"_id": line.ProductID}
+		switch {
+		case reservedMul < 0:
+			// Consuming a reservation: it must exist, and so must the units when shipping
+			filter["reserved"] = bson.M{"$gte": n}
+			if countMul < 0 {
+				filter["count"] = bson.M{"$gte": n}
+			}
+		case countMul < 0 || reservedMul > 0:
+			// Taking free stock: it must not be reserved by another order
+			filter["$expr"] = availableAtLeast(n)
+		}
+
+		inc := bson.M{}
+		if countMul != 0 {
+			inc["count"] = countMul * n
+		}
+		if reservedMul != 0 {
+			inc["reserved"] = reservedMul * n
+		}
+
+		result, err := collection.UpdateOne(context.TODO(), filter, bson.M{"$inc": inc})
+		if err != nil || result.MatchedCount == 0 {
+			rollbackStock(db, order.ProductsWithCount[:i], countMul, reservedMul)
+			if err != nil {
+				return err
+			}
+
+			problem := orderLineProblem{ProductID: line.ProductID.Hex(), Reason: "insufficient_stock", Requested: n}
+			var product models.Product
+			if collection.FindOne(context.TODO(), bson.M{"_id": line.ProductID}).Decode(&product) != nil {
+				problem.Reason = "not_found"
+			} else {
+				problem.Available = product.Available()
+			}
+			return &stockError{Problems: []orderLineProblem{problem}}
+		}
+	}
+
+	recordStockMovements(db, order, movementType, countMul, reservedMul, actor)
+	return nil
+}
+
+// rollbackStock undoes the increments already applied by moveStock.
+func rollbackStock(db *mongo.Client, lines []models.ProductWithCount, countMul, reservedMul int) {
+	collection := config.GetCollection(db, "products")
+	for _, line := range lines {
+		inc := bson.M{}
+		if countMul != 0 {
+			inc["count"] = -countMul * line.Count
+		}
+		if reservedMul != 0 {
+			inc["reserved"] = -reservedMul * line.Count
+		}
+		if _, err := collection.UpdateOne(context.TODO(), bson.M{"_id": line.ProductID}, bson.M{"$inc": inc}); err != nil {
+			log.Printf("Failed to roll back stock of product %s: %v", line.ProductID.Hex(), err)
+		}
+	}
+}
+
+// recordStockMovements writes one ledger entry per order line.
+func recordStockMovements(db *mongo.Client, order models.Order, movementType string, countMul, reservedMul int, actor models.OrderStatusChange) {
+	if len(order.ProductsWithCount) == 0 {
+		return
+	}
+
+	now := time.Now()
+	orderID := order.ID
+	entries := make([]interface{}, 0, len(order.ProductsWithCount))
+	for _, line := range order.ProductsWithCount {
+		entries = append(entries, models.StockMovement{
+			ProductID:     line.ProductID,
+			OrderID:       &orderID,
+			Type:          movementType,
+			CountDelta:    countMul * line.Count,
+			ReservedDelta: reservedMul * line.Count,
+			ActorType:     actor.ActorType,
+			ActorID:       actor.ActorID,
+			ActorName:     actor.ActorName,
+			Note:          actor.Note,
+			CreatedAt:     now,
+		})
+	}
+
+	if _, err := config.GetCollection(db, "stock_movements").InsertMany(context.TODO(), entries); err != nil {
+		log.Printf("Failed to record stock movements for order %s: %v", order.ID.Hex(), err)
+	}
+}
+
+// recordStockAdjustment writes a ledger entry for a manual change of a product's count.
+func recordStockAdjustment(db *mongo.Client, productID primitive.ObjectID, delta int, actor models.OrderStatusChange) {
+	if delta == 0 {
+		return
+	}
+
+	_, err := config.GetCollection(db, "stock_movements").InsertOne(context.TODO(), models.StockMovement{
+		ProductID:  productID,
+		Type:       models.StockMovementAdjustment,
+		CountDelta: delta,
+		ActorType:  actor.ActorType,
+		ActorID:    actor.ActorID,
+		ActorName:  actor.ActorName,
+		Note:       actor.Note,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to record stock adjustment for product %s: %v", productID.Hex(), err)
+	}
+}
+
+// stockEffect describes how a status transition moves stock.
+type stockEffect struct {
+	movementType string
+	countMul     int
+	reservedMul  int
+	// reserved is the order's stock_reserved flag after the transition
+	reserved bool
+}
+
+// stockEffectFor returns the stock change for moving order to status, or nil
+// when the transition does not touch stock. Confirming reserves, shipping
+// consumes the reservation (or free stock for orders confirmed before
+// reservations existed) and cancelling a confirmed order releases it.
+func stockEffectFor(order models.Order, to string) *stockEffect {
+	switch to {
+	case models.OrderStatusConfirmed:
+		return &stockEffect{models.StockMovementReserve, 0, 1, true}
+	case models.OrderStatusShipped:
+		if order.StockReserved {
+			return &stockEffect{models.StockMovementShip, -1, -1, false}
+		}
+		return &stockEffect{models.StockMovementShip, -1, 0, false}
+	case models.OrderStatusCancelled:
+		if order.StockReserved {
+			return &stockEffect{models.StockMovementRelease, 0, -1, false}
+		}
+	}
+	return nil
+}
+
+// revertStock undoes a stock effect that was applied for a status change which
+// could not be saved, and records the reversal in the ledger.
+func revertStock(db *mongo.Client, order models.Order, effect *stockEffect, actor models.OrderStatusChange) {
+	rollbackStock(db, order.ProductsWithCount, effect.countMul, effect.reservedMul)
+	recordStockMovements(db, order, models.StockMovementRevert, -effect.countMul, -effect.reservedMul, actor)
+}
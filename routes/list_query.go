@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/query"
+)
+
+// Fields the list endpoints can be filtered and sorted on. Resources served by
+// genericCRUD accept every scalar field of their model.
+var (
+	reviewQuery  = query.NewSpec(models.Reviews{}, "id", "name", "phone", "email", "created_at")
+	productQuery = query.NewSpec(models.Product{},
+		"id", "name", "ads_title", "shtrix_number", "serial_number", "price", "discount", "tax",
+		"count", "reserved", "category_id", "top_category_id", "created_at", "updated_at")
+	orderQuery = query.NewSpec(models.Order{},
+		"id", "order_number", "phone", "pay_type", "client_id", "status", "stock_reserved",
+		"subtotal", "discount_total", "tax_total", "total_amount", "created_at", "updated_at")
+	contractQuery = query.NewSpec(models.Contract{},
+		"id", "contract_number", "client_id", "counterparty_id", "company_id", "funnel_id",
+		"deal_date", "contract_amount", "contract_currency", "created_at", "updated_at")
+	clientQuery = query.NewSpec(models.Client{},
+		"id", "first_name", "last_name", "email", "phone", "company", "order_count",
+		"total_amount", "created_at", "updated_at")
+	counterpartyQuery = query.NewSpec(models.Counterparty{},
+		"id", "first_name", "last_name", "email", "phone", "company", "order_count",
+		"total_amount", "created_at", "updated_at")
+	companyQuery = query.NewSpec(models.Company{},
+		"id", "name", "email", "inn", "phone", "order_count", "total_amount", "created_at", "updated_at")
+)
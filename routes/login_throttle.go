@@ -0,0 +1,191 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Failed attempts allowed before an account or IP gets locked.
+	accountFailureThreshold = 5
+	ipFailureThreshold      = 20
+
+	// Lockouts start at baseLockoutDuration and double with every further failure.
+	baseLockoutDuration = time.Minute
+	maxLockoutDuration  = time.Hour
+
+	// Failure counters are forgotten after this long without a new failure.
+	failureWindow = 15 * time.Minute
+)
+
+// loginThrottleTarget identifies one counter tracked for a login attempt.
+type loginThrottleTarget struct {
+	Key         string
+	Scope       string
+	SubjectType string
+	Identifier  string
+	Threshold   int
+}
+
+// accountThrottle returns the per-account counter, e.g. "admin:account:root".
+func accountThrottle(subjectType, identifier string) loginThrottleTarget {
+	return loginThrottleTarget{
+		Key:         subjectType + ":account:" + identifier,
+		Scope:       "account",
+		SubjectType: subjectType,
+		Identifier:  identifier,
+		Threshold:   accountFailureThreshold,
+	}
+}
+
+// ipThrottle returns the per-IP counter shared by admin and user logins.
+func ipThrottle(ip string) loginThrottleTarget {
+	return loginThrottleTarget{
+		Key:        "ip:" + ip,
+		Scope:      "ip",
+		Identifier: ip,
+		Threshold:  ipFailureThreshold,
+	}
+}
+
+// lockoutDuration returns how long to lock after the given number of failures.
+func lockoutDuration(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	d := time.Duration(float64(baseLockoutDuration) * math.Pow(2, float64(failures-threshold)))
+	if d <= 0 || d > maxLockoutDuration {
+		return maxLockoutDuration
+	}
+	return d
+}
+
+// ensureLoginThrottleIndexes creates the unique key and TTL indexes.
+func ensureLoginThrottleIndexes(db *mongo.Client) {
+	collection := config.GetCollection(db, "login_throttles")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Printf("Failed to create login throttle indexes: %v", err)
+	}
+}
+
+// loginLockedFor returns the remaining lockout of the most restrictive target,
+// or zero when none of them is locked.
+func loginLockedFor(db *mongo.Client, targets ...loginThrottleTarget) time.Duration {
+	keys := make([]string, 0, len(targets))
+	for _, t := range targets {
+		keys = append(keys, t.Key)
+	}
+
+	now := time.Now()
+	collection := config.GetCollection(db, "login_throttles")
+	cursor, err := collection.Find(context.TODO(), bson.M{
+		"key":          bson.M{"$in": keys},
+		"locked_until": bson.M{"$gt": now},
+	})
+	if err != nil {
+		log.Printf("Failed to check login throttles: %v", err)
+		return 0
+	}
+	defer cursor.Close(context.TODO())
+
+	var throttles []models.LoginThrottle
+	if err := cursor.All(context.TODO(), &throttles); err != nil {
+		return 0
+	}
+
+	var remaining time.Duration
+	for _, t := range throttles {
+		if left := t.LockedUntil.Sub(now); left > remaining {
+			remaining = left
+		}
+	}
+	return remaining
+}
+
+// registerLoginFailure increments the failure counters of every target and
+// locks those that crossed their threshold.
+func registerLoginFailure(db *mongo.Client, targets ...loginThrottleTarget) {
+	collection := config.GetCollection(db, "login_throttles")
+	now := time.Now()
+
+	for _, target := range targets {
+		// Restart the count when the previous window has already expired
+		update := mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"key":          target.Key,
+				"scope":        target.Scope,
+				"subject_type": target.SubjectType,
+				"identifier":   target.Identifier,
+				"failures": bson.M{"$cond": bson.A{
+					bson.M{"$gt": bson.A{"$expires_at", now}},
+					bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}},
+					1,
+				}},
+				"last_failure_at": now,
+				"expires_at":      now.Add(failureWindow),
+			}}},
+		}
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		var throttle models.LoginThrottle
+		if err := collection.FindOneAndUpdate(context.TODO(), bson.M{"key": target.Key}, update, opts).Decode(&throttle); err != nil {
+			log.Printf("Failed to register login failure for %s: %v", target.Key, err)
+			continue
+		}
+
+		lockout := lockoutDuration(throttle.Failures, target.Threshold)
+		if lockout == 0 {
+			continue
+		}
+
+		lockedUntil := now.Add(lockout)
+		expiresAt := lockedUntil
+		if window := now.Add(failureWindow); window.After(expiresAt) {
+			expiresAt = window
+		}
+		collection.UpdateOne(context.TODO(), bson.M{"key": target.Key}, bson.M{"$set": bson.M{
+			"locked_until": lockedUntil,
+			"expires_at":   expiresAt,
+		}})
+	}
+}
+
+// clearLoginFailures forgets the counters of the given targets, e.g. after a
+// successful login or when an admin unlocks an account.
+func clearLoginFailures(db *mongo.Client, targets ...loginThrottleTarget) (int64, error) {
+	keys := make([]string, 0, len(targets))
+	for _, t := range targets {
+		keys = append(keys, t.Key)
+	}
+
+	collection := config.GetCollection(db, "login_throttles")
+	result, err := collection.DeleteMany(context.TODO(), bson.M{"key": bson.M{"$in": keys}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// tooManyLoginAttempts sets Retry-After and returns the 429 for a locked login.
+func tooManyLoginAttempts(c *fiber.Ctx, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return utils.TooManyRequests("Too many failed login attempts. Please try again later.").
+		With("retry_after_seconds", seconds)
+}
@@ -1,14 +1,16 @@
 package routes
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"strconv"
+	"log"
 	"time"
 
 	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
 	"fiber-ecommerce/models"
+	"fiber-ecommerce/query"
+	"fiber-ecommerce/utils"
+	"fiber-ecommerce/validation"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -19,33 +21,29 @@ import (
 
 // Company CRUD
 func CompanyRoutes(app fiber.Router, db *mongo.Client) {
-	companies := app.Group("/companies")
+	companies := app.Group("/companies", middleware.AdminJWTMiddleware(salesRoles...))
+	registerTrash("companies", "companies", salesRoles)
 
 	companies.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "companies")
 
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		if limit <= 0 {
-			limit = 10
-		}
-		if page <= 0 {
-			page = 1
+		list, err := companyQuery.Parse(c)
+		if err != nil {
+			return err
 		}
-		skip := (page - 1) * limit
+		filter := list.Apply(notTrashed(bson.M{}))
 
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
-
-		cursor, err := collection.Find(context.TODO(), bson.M{}, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch companies"})
+			return utils.Internal("Failed to fetch companies")
 		}
 		defer cursor.Close(context.TODO())
 
 		var companies []models.Company
 		if err = cursor.All(context.TODO(), &companies); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode companies"})
+			return utils.Internal("Failed to decode companies")
 		}
+		companies, next := query.Page(list, companies)
 
 		for i := range companies {
 			if companies[i].OrderHistory == nil {
@@ -53,27 +51,25 @@ func CompanyRoutes(app fiber.Router, db *mongo.Client) {
 			}
 		}
 
-		total, _ := collection.CountDocuments(context.TODO(), bson.M{})
+		data, err := list.Select(companies)
+		if err != nil {
+			return utils.Internal("Failed to encode companies")
+		}
 
-		return c.JSON(fiber.Map{
-			"data":  companies,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(list.Response(data, next, collection, filter))
 	})
 
 	companies.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "companies")
 		var company models.Company
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&company)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&company)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Company not found"})
+			return utils.NotFound("Company not found")
 		}
 
 		if company.OrderHistory == nil {
@@ -83,77 +79,39 @@ func CompanyRoutes(app fiber.Router, db *mongo.Client) {
 		return c.JSON(company)
 	})
 
-	type companyPayload struct {
-		Name    string  `json:"name"`
-		Email   string  `json:"email"`
-		Inn     string  `json:"inn"`
-		Address string  `json:"address"`
-		Phone   string  `json:"phone"`
-		Comment *string `json:"comment"`
-	}
-
 	companies.Post("/", func(c *fiber.Ctx) error {
-		var payload companyPayload
-		if err := c.BodyParser(&payload); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-		}
-
-		if payload.Name == "" || payload.Email == "" || payload.Inn == "" || payload.Address == "" || payload.Phone == "" {
-			return c.Status(400).JSON(fiber.Map{"error": "Name, email, inn, address and phone are required"})
+		var company models.Company
+		if errs := validation.Parse(c.Body(), &company); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		now := time.Now()
-		company := models.Company{
-			Name:         payload.Name,
-			OrderCount:   0,
-			TotalAmount:  models.NewFlexFloat64(0),
-			Email:        payload.Email,
-			Inn:          payload.Inn,
-			Address:      payload.Address,
-			Phone:        payload.Phone,
-			Comment:      payload.Comment,
-			OrderHistory: []models.OrderHistoryEntry{},
-			CreatedAt:    now,
-			UpdatedAt:    now,
-		}
+		company.TotalAmount = models.NewFlexFloat64(0)
+		company.Totals = map[string]float64{}
+		company.OrderHistory = []models.OrderHistoryEntry{}
+		company.CreatedAt = now
+		company.UpdatedAt = now
 
 		collection := config.GetCollection(db, "companies")
 		result, err := collection.InsertOne(context.TODO(), company)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create company"})
+			return utils.Internal("Failed to create company")
 		}
 
 		company.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "companies", company.ID, nil, company)
 		return c.Status(201).JSON(company)
 	})
 
 	companies.Put("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-		}
-
-		delete(updateData, "_id")
-		delete(updateData, "created_at")
-
-		if orderCount, ok := updateData["order_count"]; ok {
-			if v, valid := toInt(orderCount); valid {
-				updateData["order_count"] = v
-			} else {
-				delete(updateData, "order_count")
-			}
-		}
-		if totalAmount, ok := updateData["total_amount"]; ok {
-			if v, valid := toFloat64(totalAmount); valid {
-				updateData["total_amount"] = v
-			} else {
-				delete(updateData, "total_amount")
-			}
+		updateData, errs := validation.Decode(c.Body(), models.Company{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		updateData["updated_at"] = time.Now()
@@ -161,17 +119,16 @@ func CompanyRoutes(app fiber.Router, db *mongo.Client) {
 		collection := config.GetCollection(db, "companies")
 		update := bson.M{"$set": updateData}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update company"})
+		err = auditedUpdate(c, db, "companies", notTrashed(bson.M{"_id": id}), update)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Company not found")
 		}
-
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Company not found"})
+		if err != nil {
+			return utils.Internal("Failed to update company")
 		}
 
 		var company models.Company
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&company)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&company)
 		if company.OrderHistory == nil {
 			company.OrderHistory = []models.OrderHistoryEntry{}
 		}
@@ -182,41 +139,37 @@ func CompanyRoutes(app fiber.Router, db *mongo.Client) {
 	companies.Delete("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, "companies")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		report, err := safeDelete(c, db, "companies", id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete company"})
+			return deleteError(err, report, "Company not found", "Failed to delete company")
 		}
 
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Company not found"})
-		}
-
-		return c.JSON(fiber.Map{"message": "Company deleted successfully"})
+		return c.JSON(deleteResult("Company deleted successfully", report))
 	})
 }
 
 // Funnel CRUD
 func FunnelRoutes(app fiber.Router, db *mongo.Client) {
-	funnels := app.Group("/funnels")
+	funnels := app.Group("/funnels", middleware.AdminJWTMiddleware(salesRoles...))
+	registerTrash("funnels", "funnels", salesRoles)
 
 	funnels.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "funnels")
 
 		opts := options.Find().SetSort(bson.D{{Key: "order", Value: 1}})
 
-		cursor, err := collection.Find(context.TODO(), bson.M{}, opts)
+		cursor, err := collection.Find(context.TODO(), notTrashed(bson.M{}), opts)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch funnels"})
+			return utils.Internal("Failed to fetch funnels")
 		}
 		defer cursor.Close(context.TODO())
 
 		var funnelsList []models.Funnel
 		if err = cursor.All(context.TODO(), &funnelsList); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode funnels"})
+			return utils.Internal("Failed to decode funnels")
 		}
 
 		return c.JSON(funnelsList)
@@ -225,144 +178,107 @@ func FunnelRoutes(app fiber.Router, db *mongo.Client) {
 	funnels.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "funnels")
 		var funnel models.Funnel
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&funnel)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&funnel)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Funnel stage not found"})
+			return utils.NotFound("Funnel stage not found")
 		}
 
 		return c.JSON(funnel)
 	})
 
-	type funnelPayload struct {
-		Name    string `json:"name"`
-		Color   string `json:"color"`
-		Comment string `json:"comment"`
-		Order   *int   `json:"order"`
-	}
-
 	funnels.Post("/", func(c *fiber.Ctx) error {
-		var payload funnelPayload
-		if err := c.BodyParser(&payload); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-		}
-
-		if payload.Name == "" || payload.Color == "" || payload.Order == nil {
-			return c.Status(400).JSON(fiber.Map{"error": "name, color and order are required"})
+		var funnel models.Funnel
+		if errs := validation.Parse(c.Body(), &funnel); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		now := time.Now()
-		funnel := models.Funnel{
-			Name:      payload.Name,
-			Color:     payload.Color,
-			Comment:   payload.Comment,
-			Order:     *payload.Order,
-			CreatedAt: now,
-			UpdatedAt: now,
-		}
+		funnel.CreatedAt = now
+		funnel.UpdatedAt = now
 
 		collection := config.GetCollection(db, "funnels")
 		result, err := collection.InsertOne(context.TODO(), funnel)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create funnel stage"})
+			return utils.Internal("Failed to create funnel stage")
 		}
 
 		funnel.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "funnels", funnel.ID, nil, funnel)
 		return c.Status(201).JSON(funnel)
 	})
 
 	funnels.Put("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
-		}
-
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		delete(updateData, "_id")
-		delete(updateData, "created_at")
-
-		if orderVal, ok := updateData["order"]; ok {
-			if v, valid := toInt(orderVal); valid {
-				updateData["order"] = v
-			} else {
-				return c.Status(400).JSON(fiber.Map{"error": "order must be a number"})
-			}
+		updateData, errs := validation.Decode(c.Body(), models.Funnel{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "funnels")
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": updateData})
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update funnel stage"})
+		err = auditedUpdate(c, db, "funnels", notTrashed(bson.M{"_id": id}), bson.M{"$set": updateData})
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Funnel stage not found")
 		}
-
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Funnel stage not found"})
+		if err != nil {
+			return utils.Internal("Failed to update funnel stage")
 		}
 
 		var funnel models.Funnel
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&funnel)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&funnel)
 		return c.JSON(funnel)
 	})
 
 	funnels.Delete("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, "funnels")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		report, err := safeDelete(c, db, "funnels", id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete funnel stage"})
-		}
-
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Funnel stage not found"})
+			return deleteError(err, report, "Funnel stage not found", "Failed to delete funnel stage")
 		}
 
-		return c.JSON(fiber.Map{"message": "Funnel stage deleted successfully"})
+		return c.JSON(deleteResult("Funnel stage deleted successfully", report))
 	})
 }
 
 // Counterparty CRUD
 func CounterpartyRoutes(app fiber.Router, db *mongo.Client) {
-	counterparties := app.Group("/counterparties")
+	counterparties := app.Group("/counterparties", middleware.AdminJWTMiddleware(salesRoles...))
+	registerTrash("counterparties", "counterparties", salesRoles)
 
 	counterparties.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "counterparties")
 
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		if limit <= 0 {
-			limit = 10
-		}
-		if page <= 0 {
-			page = 1
+		list, err := counterpartyQuery.Parse(c)
+		if err != nil {
+			return err
 		}
-		skip := (page - 1) * limit
-
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+		filter := list.Apply(notTrashed(bson.M{}))
 
-		cursor, err := collection.Find(context.TODO(), bson.M{}, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch counterparties"})
+			return utils.Internal("Failed to fetch counterparties")
 		}
 		defer cursor.Close(context.TODO())
 
 		var counterparties []models.Counterparty
 		if err = cursor.All(context.TODO(), &counterparties); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode counterparties"})
+			return utils.Internal("Failed to decode counterparties")
 		}
+		counterparties, next := query.Page(list, counterparties)
 
 		for i := range counterparties {
 			if counterparties[i].OrderHistory == nil {
@@ -370,27 +286,25 @@ func CounterpartyRoutes(app fiber.Router, db *mongo.Client) {
 			}
 		}
 
-		total, _ := collection.CountDocuments(context.TODO(), bson.M{})
+		data, err := list.Select(counterparties)
+		if err != nil {
+			return utils.Internal("Failed to encode counterparties")
+		}
 
-		return c.JSON(fiber.Map{
-			"data":  counterparties,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(list.Response(data, next, collection, filter))
 	})
 
 	counterparties.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "counterparties")
 		var counterparty models.Counterparty
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&counterparty)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&counterparty)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Counterparty not found"})
+			return utils.NotFound("Counterparty not found")
 		}
 
 		if counterparty.OrderHistory == nil {
@@ -400,81 +314,54 @@ func CounterpartyRoutes(app fiber.Router, db *mongo.Client) {
 		return c.JSON(counterparty)
 	})
 
-	type counterpartyPayload struct {
-		FirstName    string  `json:"first_name"`
-		LastName     string  `json:"last_name"`
-		Email        string  `json:"email"`
-		Phone        string  `json:"phone"`
-		CompanyPhone string  `json:"company_phone"`
-		Company      string  `json:"company"`
-		Address      string  `json:"address"`
-		Comment      *string `json:"comment"`
-	}
-
 	counterparties.Post("/", func(c *fiber.Ctx) error {
-		var payload counterpartyPayload
-		if err := c.BodyParser(&payload); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-		}
-
-		if payload.FirstName == "" || payload.LastName == "" || payload.Email == "" || payload.Phone == "" {
-			return c.Status(400).JSON(fiber.Map{"error": "First name, last name, email and phone are required"})
+		var counterparty models.Counterparty
+		if errs := validation.Parse(c.Body(), &counterparty); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		now := time.Now()
-		counterparty := models.Counterparty{
-			FirstName:    payload.FirstName,
-			LastName:     payload.LastName,
-			Email:        payload.Email,
-			Phone:        payload.Phone,
-			CompanyPhone: payload.CompanyPhone,
-			Company:      payload.Company,
-			Address:      payload.Address,
-			Comment:      payload.Comment,
-			OrderHistory: []models.OrderHistoryEntry{},
-			CreatedAt:    now,
-			UpdatedAt:    now,
-		}
+		counterparty.OrderHistory = []models.OrderHistoryEntry{}
+		counterparty.CreatedAt = now
+		counterparty.UpdatedAt = now
 
 		collection := config.GetCollection(db, "counterparties")
 		result, err := collection.InsertOne(context.TODO(), counterparty)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create counterparty"})
+			return utils.Internal("Failed to create counterparty")
 		}
 
 		counterparty.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "counterparties", counterparty.ID, nil, counterparty)
 		return c.Status(201).JSON(counterparty)
 	})
 
 	counterparties.Put("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		updateData, errs := validation.Decode(c.Body(), models.Counterparty{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		delete(updateData, "_id")
-		delete(updateData, "created_at")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "counterparties")
 		update := bson.M{"$set": updateData}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update counterparty"})
+		err = auditedUpdate(c, db, "counterparties", notTrashed(bson.M{"_id": id}), update)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Counterparty not found")
 		}
-
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Counterparty not found"})
+		if err != nil {
+			return utils.Internal("Failed to update counterparty")
 		}
 
 		var counterparty models.Counterparty
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&counterparty)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&counterparty)
 		if counterparty.OrderHistory == nil {
 			counterparty.OrderHistory = []models.OrderHistoryEntry{}
 		}
@@ -484,41 +371,32 @@ func CounterpartyRoutes(app fiber.Router, db *mongo.Client) {
 	counterparties.Delete("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, "counterparties")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		report, err := safeDelete(c, db, "counterparties", id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete counterparty"})
+			return deleteError(err, report, "Counterparty not found", "Failed to delete counterparty")
 		}
 
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Counterparty not found"})
-		}
-
-		return c.JSON(fiber.Map{"message": "Counterparty deleted successfully"})
+		return c.JSON(deleteResult("Counterparty deleted successfully", report))
 	})
 }
 
 // Contract CRUD
 func ContractRoutes(app fiber.Router, db *mongo.Client) {
-	contracts := app.Group("/contracts")
+	contracts := app.Group("/contracts", middleware.AdminJWTMiddleware(salesRoles...))
+	registerTrash("contracts", "contracts", salesRoles)
 
 	contracts.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "contracts")
 
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		if limit <= 0 {
-			limit = 10
-		}
-		if page <= 0 {
-			page = 1
+		list, err := contractQuery.Parse(c)
+		if err != nil {
+			return err
 		}
-		skip := (page - 1) * limit
 
-		filter := bson.M{}
+		filter := notTrashed(bson.M{})
 		if clientID := c.Query("client_id"); clientID != "" {
 			if id, err := primitive.ObjectIDFromHex(clientID); err == nil {
 				filter["client_id"] = id
@@ -529,19 +407,22 @@ func ContractRoutes(app fiber.Router, db *mongo.Client) {
 				filter["company_id"] = id
 			}
 		}
+		if contractNumber := c.Query("contract_number"); contractNumber != "" {
+			filter["contract_number"] = contractNumber
+		}
+		filter = list.Apply(filter)
 
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
-
-		cursor, err := collection.Find(context.TODO(), filter, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch contracts"})
+			return utils.Internal("Failed to fetch contracts")
 		}
 		defer cursor.Close(context.TODO())
 
 		var contractsList []models.Contract
 		if err = cursor.All(context.TODO(), &contractsList); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode contracts"})
+			return utils.Internal("Failed to decode contracts")
 		}
+		contractsList, next := query.Page(list, contractsList)
 
 		for i := range contractsList {
 			if contractsList[i].Products == nil {
@@ -549,27 +430,25 @@ func ContractRoutes(app fiber.Router, db *mongo.Client) {
 			}
 		}
 
-		total, _ := collection.CountDocuments(context.TODO(), filter)
+		data, err := list.Select(contractsList)
+		if err != nil {
+			return utils.Internal("Failed to encode contracts")
+		}
 
-		return c.JSON(fiber.Map{
-			"data":  contractsList,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(list.Response(data, next, collection, filter))
 	})
 
 	contracts.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "contracts")
 		var contract models.Contract
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&contract)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&contract)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Contract not found"})
+			return utils.NotFound("Contract not found")
 		}
 
 		if contract.Products == nil {
@@ -581,22 +460,8 @@ func ContractRoutes(app fiber.Router, db *mongo.Client) {
 
 	contracts.Post("/", func(c *fiber.Ctx) error {
 		var contract models.Contract
-		if err := c.BodyParser(&contract); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-		}
-
-		if contract.ClientID.IsZero() || contract.CounterpartyID.IsZero() || contract.CompanyID.IsZero() {
-			return c.Status(400).JSON(fiber.Map{"error": "client_id, counterparty_id and company_id are required"})
-		}
-
-		if normalized, ok := normalizeCurrency(contract.ContractCurrency); ok {
-			contract.ContractCurrency = normalized
-		} else {
-			return c.Status(400).JSON(fiber.Map{"error": "contract_currency must be one of UZS, USD, EUR"})
-		}
-
-		if contract.DealDate.IsZero() {
-			return c.Status(400).JSON(fiber.Map{"error": "deal_date is required"})
+		if errs := validation.Parse(c.Body(), &contract); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		now := time.Now()
@@ -604,139 +469,80 @@ func ContractRoutes(app fiber.Router, db *mongo.Client) {
 			contract.Products = []models.ContractProduct{}
 		}
 
+		checks := referenceChecks(bson.M{
+			"client_id":       contract.ClientID,
+			"counterparty_id": contract.CounterpartyID,
+			"company_id":      contract.CompanyID,
+			"funnel_id":       contract.FunnelID,
+		}, contractReferences)
+		checks = append(checks, contractProductChecks(contract.Products)...)
+		broken, err := checkReferences(db, checks...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
+		}
+
 		contract.CreatedAt = now
 		contract.UpdatedAt = now
 
+		number, err := nextContractNumber(db, now)
+		if err != nil {
+			return utils.Internal("Failed to generate contract number")
+		}
+		contract.ContractNumber = number
+
 		collection := config.GetCollection(db, "contracts")
 		result, err := collection.InsertOne(context.TODO(), contract)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create contract"})
+			return utils.Internal("Failed to create contract")
 		}
 
 		contract.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "contracts", contract.ID, nil, contract)
+		syncContractParties(db, contract)
 		return c.Status(201).JSON(contract)
 	})
 
 	contracts.Put("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "contracts")
 
-		body := c.Body()
-		if len(body) == 0 {
-			return c.Status(400).JSON(fiber.Map{"error": "Request body cannot be empty"})
+		set, errs := validation.Decode(c.Body(), models.Contract{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
-
-		var raw map[string]json.RawMessage
-		if err := json.Unmarshal(body, &raw); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if len(set) == 0 {
+			return validation.Failed(validation.Error("", "invalid_body", "No updatable fields provided"))
 		}
 
+		// A null party name removes the denormalized name
 		unset := bson.M{}
-		set := bson.M{}
-
-		isNull := func(v json.RawMessage) bool {
-			return bytes.Equal(bytes.TrimSpace(v), []byte("null"))
-		}
-
-		parseObjectID := func(field string, value json.RawMessage) (primitive.ObjectID, error) {
-			var hex string
-			if err := json.Unmarshal(value, &hex); err != nil {
-				return primitive.NilObjectID, err
-			}
-			return primitive.ObjectIDFromHex(hex)
-		}
-
-		sanitized := []string{"_id", "id", "created_at", "updated_at"}
-		for _, key := range sanitized {
-			delete(raw, key)
-		}
-
-		for key, value := range raw {
-			switch key {
-			case "client_id", "counterparty_id", "company_id", "funnel_id":
-				if isNull(value) {
-					return c.Status(400).JSON(fiber.Map{"error": key + " cannot be null"})
-				}
-				oid, err := parseObjectID(key, value)
-				if err != nil {
-					return c.Status(400).JSON(fiber.Map{"error": "Invalid " + key})
-				}
-				set[key] = oid
-			case "client_name", "counterparty_name", "company_name":
-				if isNull(value) {
-					unset[key] = ""
-					continue
-				}
-				var name string
-				if err := json.Unmarshal(value, &name); err != nil {
-					return c.Status(400).JSON(fiber.Map{"error": "Invalid " + key})
-				}
-				set[key] = name
-			case "guarantee", "comment":
-				if isNull(value) {
-					return c.Status(400).JSON(fiber.Map{"error": key + " cannot be null"})
-				}
-				var text string
-				if err := json.Unmarshal(value, &text); err != nil {
-					return c.Status(400).JSON(fiber.Map{"error": "Invalid " + key})
-				}
-				set[key] = text
-			case "deal_date":
-				if isNull(value) {
-					return c.Status(400).JSON(fiber.Map{"error": "deal_date cannot be null"})
-				}
-				var dateStr string
-				if err := json.Unmarshal(value, &dateStr); err != nil {
-					return c.Status(400).JSON(fiber.Map{"error": "Invalid deal_date"})
-				}
-				parsed, err := time.Parse(time.RFC3339, dateStr)
-				if err != nil {
-					return c.Status(400).JSON(fiber.Map{"error": "deal_date must be in RFC3339 format"})
-				}
-				set["deal_date"] = parsed
-			case "contract_currency":
-				if isNull(value) {
-					return c.Status(400).JSON(fiber.Map{"error": "contract_currency cannot be null"})
-				}
-				var currency string
-				if err := json.Unmarshal(value, &currency); err != nil {
-					return c.Status(400).JSON(fiber.Map{"error": "Invalid contract_currency"})
-				}
-				if normalized, ok := normalizeCurrency(currency); ok {
-					set["contract_currency"] = normalized
-				} else {
-					return c.Status(400).JSON(fiber.Map{"error": "contract_currency must be one of UZS, USD, EUR"})
-				}
-			case "contract_amount", "pay_card", "pay_cash":
-				var flex models.FlexFloat64
-				if err := json.Unmarshal(value, &flex); err != nil {
-					return c.Status(400).JSON(fiber.Map{"error": "Invalid " + key})
-				}
-				set[key] = flex
-			case "products":
-				if isNull(value) {
-					set[key] = []models.ContractProduct{}
-					continue
-				}
-				var products []models.ContractProduct
-				if err := json.Unmarshal(value, &products); err != nil {
-					return c.Status(400).JSON(fiber.Map{"error": "Invalid products"})
-				}
-				if products == nil {
-					products = []models.ContractProduct{}
-				}
-				set[key] = products
-			default:
-				// Ignore unknown fields to allow forward compatibility.
+		for _, key := range []string{"client_name", "counterparty_name", "company_name"} {
+			if name, ok := set[key].(*string); ok && name == nil {
+				delete(set, key)
+				unset[key] = ""
 			}
 		}
+		if products, ok := set["products"].([]models.ContractProduct); ok && products == nil {
+			set["products"] = []models.ContractProduct{}
+		}
 
-		if len(set) == 0 && len(unset) == 0 {
-			return c.Status(400).JSON(fiber.Map{"error": "No updatable fields provided"})
+		checks := referenceChecks(set, contractReferences)
+		if products, ok := set["products"].([]models.ContractProduct); ok {
+			checks = append(checks, contractProductChecks(products)...)
+		}
+		broken, err := checkReferences(db, checks...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
 		}
 
 		set["updated_at"] = time.Now()
@@ -749,18 +555,22 @@ func ContractRoutes(app fiber.Router, db *mongo.Client) {
 			updateDoc["$unset"] = unset
 		}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, updateDoc)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update contract"})
+		// Keep the previous version so parties the contract moved away from are refreshed too
+		var previous models.Contract
+		err = collection.FindOneAndUpdate(context.TODO(), notTrashed(bson.M{"_id": id}), updateDoc).Decode(&previous)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Contract not found")
 		}
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Contract not found"})
+		if err != nil {
+			return utils.Internal("Failed to update contract")
 		}
 
 		var updated models.Contract
-		if err := collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&updated); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to load updated contract"})
+		if err := collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&updated); err != nil {
+			return utils.Internal("Failed to load updated contract")
 		}
+		recordAudit(c, db, models.AuditActionUpdate, "contracts", id, previous, updated)
+		syncContractParties(db, previous, updated)
 
 		if updated.Products == nil {
 			updated.Products = []models.ContractProduct{}
@@ -772,47 +582,50 @@ func ContractRoutes(app fiber.Router, db *mongo.Client) {
 	contracts.Delete("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, "contracts")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete contract"})
+		var contract models.Contract
+		if err := config.GetCollection(db, "contracts").FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&contract); err != nil {
+			return utils.NotFound("Contract not found")
 		}
 
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Contract not found"})
+		report, err := safeDelete(c, db, "contracts", id)
+		if err != nil {
+			return deleteError(err, report, "Contract not found", "Failed to delete contract")
 		}
+		syncContractParties(db, contract)
 
-		return c.JSON(fiber.Map{"message": "Contract deleted successfully"})
+		return c.JSON(deleteResult("Contract deleted successfully", report))
 	})
 }
 
 // Client CRUD
 func ClientRoutes(app fiber.Router, db *mongo.Client) {
-	clients := app.Group("/clients")
+	clients := app.Group("/clients", middleware.AdminJWTMiddleware(salesRoles...))
+	registerTrash("clients", "clients", salesRoles)
 
 	// Get all clients
 	clients.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "clients")
 
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		skip := (page - 1) * limit
-
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+		list, err := clientQuery.Parse(c)
+		if err != nil {
+			return err
+		}
+		filter := list.Apply(notTrashed(bson.M{}))
 
-		cursor, err := collection.Find(context.TODO(), bson.M{}, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch clients"})
+			return utils.Internal("Failed to fetch clients")
 		}
 		defer cursor.Close(context.TODO())
 
 		var clients []models.Client
 		if err = cursor.All(context.TODO(), &clients); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode clients"})
+			return utils.Internal("Failed to decode clients")
 		}
+		clients, next := query.Page(list, clients)
 
 		for i := range clients {
 			if clients[i].OrderHistory == nil {
@@ -820,28 +633,26 @@ func ClientRoutes(app fiber.Router, db *mongo.Client) {
 			}
 		}
 
-		total, _ := collection.CountDocuments(context.TODO(), bson.M{})
+		data, err := list.Select(clients)
+		if err != nil {
+			return utils.Internal("Failed to encode clients")
+		}
 
-		return c.JSON(fiber.Map{
-			"data":  clients,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(list.Response(data, next, collection, filter))
 	})
 
 	// Get single client
 	clients.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "clients")
 		var client models.Client
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&client)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&client)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Client not found"})
+			return utils.NotFound("Client not found")
 		}
 
 		if client.OrderHistory == nil {
@@ -851,51 +662,27 @@ func ClientRoutes(app fiber.Router, db *mongo.Client) {
 		return c.JSON(client)
 	})
 
-	type clientPayload struct {
-		FirstName    string  `json:"first_name"`
-		LastName     string  `json:"last_name"`
-		Email        string  `json:"email"`
-		Phone        string  `json:"phone"`
-		CompanyPhone string  `json:"company_phone"`
-		Company      string  `json:"company"`
-		Address      string  `json:"address"`
-		Comment      *string `json:"comment"`
-	}
-
 	// Create client
 	clients.Post("/", func(c *fiber.Ctx) error {
-		var payload clientPayload
-		if err := c.BodyParser(&payload); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-		}
-
-		if payload.FirstName == "" || payload.LastName == "" || payload.Email == "" || payload.Phone == "" {
-			return c.Status(400).JSON(fiber.Map{"error": "First name, last name, email and phone are required"})
+		var client models.Client
+		if errs := validation.Parse(c.Body(), &client); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		now := time.Now()
-		client := models.Client{
-			FirstName:    payload.FirstName,
-			LastName:     payload.LastName,
-			Email:        payload.Email,
-			Phone:        payload.Phone,
-			CompanyPhone: payload.CompanyPhone,
-			Company:      payload.Company,
-			Address:      payload.Address,
-			Comment:      payload.Comment,
-			OrderHistory: []models.OrderHistoryEntry{},
-			CreatedAt:    now,
-			UpdatedAt:    now,
-		}
+		client.OrderHistory = []models.OrderHistoryEntry{}
+		client.CreatedAt = now
+		client.UpdatedAt = now
 
 		collection := config.GetCollection(db, "clients")
 
 		result, err := collection.InsertOne(context.TODO(), client)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create client"})
+			return utils.Internal("Failed to create client")
 		}
 
 		client.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "clients", client.ID, nil, client)
 		return c.Status(201).JSON(client)
 	})
 
@@ -903,32 +690,29 @@ func ClientRoutes(app fiber.Router, db *mongo.Client) {
 	clients.Put("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		updateData, errs := validation.Decode(c.Body(), models.Client{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		delete(updateData, "_id")
-		delete(updateData, "created_at")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "clients")
 		update := bson.M{"$set": updateData}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update client"})
+		err = auditedUpdate(c, db, "clients", notTrashed(bson.M{"_id": id}), update)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Client not found")
 		}
-
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Client not found"})
+		if err != nil {
+			return utils.Internal("Failed to update client")
 		}
 
 		var client models.Client
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&client)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&client)
 		if client.OrderHistory == nil {
 			client.OrderHistory = []models.OrderHistoryEntry{}
 		}
@@ -939,155 +723,398 @@ func ClientRoutes(app fiber.Router, db *mongo.Client) {
 	clients.Delete("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, "clients")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		report, err := safeDelete(c, db, "clients", id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete client"})
-		}
-
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Client not found"})
+			return deleteError(err, report, "Client not found", "Failed to delete client")
 		}
 
-		return c.JSON(fiber.Map{"message": "Client deleted successfully"})
+		return c.JSON(deleteResult("Client deleted successfully", report))
 	})
 }
 
 // Order CRUD
 func OrderRoutes(app fiber.Router, db *mongo.Client) {
 	orders := app.Group("/orders")
+	registerTrash("orders", "orders", salesRoles)
+
+	// My orders - registered before /:id so "my-orders" is not taken for an order ID
+	orders.Get("/my-orders", middleware.UserJWTMiddleware(), func(c *fiber.Ctx) error {
+		userID, err := primitive.ObjectIDFromHex(c.Locals("user_id").(string))
+		if err != nil {
+			return utils.BadRequest("Invalid user ID")
+		}
+
+		list, err := orderQuery.Parse(c)
+		if err != nil {
+			return err
+		}
+
+		filter := notTrashed(bson.M{"user_id": userID})
+		if status := c.Query("status"); status != "" {
+			if !utils.IsValidOrderStatus(status) {
+				return utils.BadRequest("Invalid order status")
+			}
+			filter["status"] = statusFilter(status)
+		}
+		filter = list.Apply(filter)
+
+		collection := config.GetCollection(db, "orders")
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
+		if err != nil {
+			return utils.Internal("Failed to fetch orders")
+		}
+		defer cursor.Close(context.TODO())
+
+		myOrders := []models.Order{}
+		if err = cursor.All(context.TODO(), &myOrders); err != nil {
+			return utils.Internal("Failed to decode orders")
+		}
+		myOrders, next := query.Page(list, myOrders)
+
+		data, err := list.Select(myOrders)
+		if err != nil {
+			return utils.Internal("Failed to encode orders")
+		}
+
+		return c.JSON(list.PaginationResponse(data, next, collection, filter))
+	})
+
+	orders.Get("/my-orders/:id", middleware.UserJWTMiddleware(), func(c *fiber.Ctx) error {
+		order, err := findMyOrder(c, db)
+		if err != nil {
+			return utils.NotFound("Order not found")
+		}
+
+		return c.JSON(order)
+	})
+
+	// Cancel my order - users may only cancel while the order is still pending
+	orders.Post("/my-orders/:id/cancel", middleware.UserJWTMiddleware(), func(c *fiber.Ctx) error {
+		order, err := findMyOrder(c, db)
+		if err != nil {
+			return utils.NotFound("Order not found")
+		}
+
+		// The body is optional and only carries a note
+		var req struct {
+			Note string `json:"note,omitempty"`
+		}
+		if len(c.Body()) > 0 {
+			if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+				return validation.Failed(errs)
+			}
+		}
+
+		change := userStatusActor(c)
+		change.To = models.OrderStatusCancelled
+		change.Note = req.Note
+
+		updated, err := changeOrderStatus(c, db, order.ID, change, models.OrderStatusPending)
+		if err == errInvalidOrderTransition {
+			return utils.Conflict("Only pending orders can be cancelled").With("current_status", order.CurrentStatus())
+		}
+		if err != nil {
+			return orderStatusError(order, change.To, err)
+		}
+
+		return c.JSON(updated)
+	})
 
 	// Get all orders
-	orders.Get("/", func(c *fiber.Ctx) error {
+	orders.Get("/", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "orders")
 
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		skip := (page - 1) * limit
+		list, err := orderQuery.Parse(c)
+		if err != nil {
+			return err
+		}
 
-		filter := bson.M{}
+		filter := notTrashed(bson.M{})
 		if clientID := c.Query("client_id"); clientID != "" {
 			if id, err := primitive.ObjectIDFromHex(clientID); err == nil {
 				filter["client_id"] = id
 			}
 		}
+		if status := c.Query("status"); status != "" {
+			if !utils.IsValidOrderStatus(status) {
+				return utils.BadRequest("Invalid order status")
+			}
+			filter["status"] = statusFilter(status)
+		}
+		if orderNumber := c.Query("order_number"); orderNumber != "" {
+			filter["order_number"] = orderNumber
+		}
+		filter = list.Apply(filter)
 
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
-
-		cursor, err := collection.Find(context.TODO(), filter, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch orders"})
+			return utils.Internal("Failed to fetch orders")
 		}
 		defer cursor.Close(context.TODO())
 
 		var orders []models.Order
 		if err = cursor.All(context.TODO(), &orders); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode orders"})
+			return utils.Internal("Failed to decode orders")
 		}
+		orders, next := query.Page(list, orders)
 
-		total, _ := collection.CountDocuments(context.TODO(), filter)
+		data, err := list.Select(orders)
+		if err != nil {
+			return utils.Internal("Failed to encode orders")
+		}
 
-		return c.JSON(fiber.Map{
-			"data":  orders,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(list.Response(data, next, collection, filter))
 	})
 
 	// Get single order
-	orders.Get("/:id", func(c *fiber.Ctx) error {
+	orders.Get("/:id", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "orders")
 		var order models.Order
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&order)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&order)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+			return utils.NotFound("Order not found")
 		}
 
 		return c.JSON(order)
 	})
 
-	// Create order
-	orders.Post("/", func(c *fiber.Ctx) error {
+	// Create order - a user token links the order to the user's account
+	orders.Post("/", middleware.OptionalUserJWTMiddleware(), func(c *fiber.Ctx) error {
 		var order models.Order
-		if err := c.BodyParser(&order); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &order); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		creator := models.OrderStatusChange{ActorType: "customer"}
+		order.UserID = nil
+		if userIDStr, ok := c.Locals("user_id").(string); ok {
+			userID, err := primitive.ObjectIDFromHex(userIDStr)
+			if err != nil {
+				return utils.BadRequest("Invalid user ID")
+			}
+			order.UserID = &userID
+			if order.Phone == "" {
+				order.Phone, _ = c.Locals("user_phone").(string)
+			}
+			creator = userStatusActor(c)
+		}
+
+		// Anyone can place an order, so only admins link it to a CRM client
+		// (PUT /orders/:id); otherwise anyone knowing a client's ID could
+		// add to its order history and totals
+		order.ClientID = nil
+
+		// Prices and totals come from the catalog, never from the client
+		lines, msg := mergeOrderLines(order.ProductsWithCount)
+		if msg != "" {
+			return validation.Failed(validation.Error("products", "invalid", msg))
+		}
+		order.ProductsWithCount = lines
+
+		problems, err := priceOrder(db, &order)
+		if err != nil {
+			return utils.Internal("Failed to price order")
+		}
+		if len(problems) > 0 {
+			return orderProblemsError(problems)
 		}
 
-		order.CreatedAt = time.Now()
-		order.UpdatedAt = time.Now()
+		// New orders always start as pending, whatever the client sends
+		now := time.Now()
+		order.Status = models.OrderStatusPending
+		creator.To = models.OrderStatusPending
+		creator.ChangedAt = now
+		order.StatusHistory = []models.OrderStatusChange{creator}
+
+		// Numbers are taken last so rejected orders do not leave gaps
+		number, err := nextOrderNumber(db, now)
+		if err != nil {
+			return utils.Internal("Failed to generate order number")
+		}
+		order.OrderNumber = number
+
+		order.CreatedAt = now
+		order.UpdatedAt = now
 		collection := config.GetCollection(db, "orders")
 
 		result, err := collection.InsertOne(context.TODO(), order)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create order"})
+			return utils.Internal("Failed to create order")
 		}
 
 		order.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "orders", order.ID, nil, order)
+		syncOrderClients(db, order)
 		return c.Status(201).JSON(order)
 	})
 
 	// Update order
-	orders.Put("/:id", func(c *fiber.Ctx) error {
+	orders.Put("/:id", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		// Status changes go through PATCH /orders/:id/status and totals
+		// are always derived from the order lines, so both are read-only here
+		updateData, errs := validation.Decode(c.Body(), models.Order{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		delete(updateData, "_id")
-		updateData["updated_at"] = time.Now()
-
 		collection := config.GetCollection(db, "orders")
-		update := bson.M{"$set": updateData}
+		filter := notTrashed(bson.M{"_id": id})
+
+		// Changing the products re-prices the order, which is only allowed while pending
+		requested, repricing := updateData["products"].([]models.ProductWithCount)
+		if repricing {
+			var existing models.Order
+			if err := collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&existing); err != nil {
+				return utils.NotFound("Order not found")
+			}
+			if existing.CurrentStatus() != models.OrderStatusPending {
+				return utils.Conflict("Products can only be changed while the order is pending")
+			}
+
+			lines, msg := mergeOrderLines(requested)
+			if msg != "" {
+				return validation.Failed(validation.Error("products", "invalid", msg))
+			}
+
+			repriced := models.Order{ProductsWithCount: lines}
+			problems, err := priceOrder(db, &repriced)
+			if err != nil {
+				return utils.Internal("Failed to price order")
+			}
+			if len(problems) > 0 {
+				return orderProblemsError(problems)
+			}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
+			updateData["products"] = repriced.ProductsWithCount
+			updateData["subtotal"] = repriced.Subtotal
+			updateData["discount_total"] = repriced.DiscountTotal
+			updateData["tax_total"] = repriced.TaxTotal
+			updateData["total_amount"] = repriced.TotalAmount
+
+			// A confirm landing after the read above holds stock for the old
+			// lines, so the write only applies while the order is still pending
+			filter["status"] = statusFilter(models.OrderStatusPending)
+		}
+
+		// Also stores client_id as an ObjectID so the client's order history can find the order
+		broken, err := checkReferences(db, referenceChecks(updateData, orderReferences)...)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update order"})
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
 		}
 
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+		updateData["updated_at"] = time.Now()
+		update := bson.M{"$set": updateData}
+
+		var previous models.Order
+		err = collection.FindOneAndUpdate(context.TODO(), filter, update).Decode(&previous)
+		if err == mongo.ErrNoDocuments && repricing {
+			return utils.Conflict("Products can only be changed while the order is pending")
+		}
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Order not found")
+		}
+		if err != nil {
+			return utils.Internal("Failed to update order")
 		}
 
 		var order models.Order
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&order)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&order)
+		recordAudit(c, db, models.AuditActionUpdate, "orders", id, previous, order)
+		syncOrderClients(db, previous, order)
+		return c.JSON(order)
+	})
+
+	// Change order status following the order lifecycle
+	orders.Patch("/:id/status", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.BadRequest("Invalid ID")
+		}
+
+		var req models.OrderStatusRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		change := adminActor(c)
+		change.To = req.Status
+		change.Note = req.Note
+
+		order, err := changeOrderStatus(c, db, id, change)
+		if err != nil {
+			return orderStatusError(order, req.Status, err)
+		}
+
 		return c.JSON(order)
 	})
 
 	// Delete order
-	orders.Delete("/:id", func(c *fiber.Ctx) error {
+	orders.Delete("/:id", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "orders")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		var order models.Order
+		if err := collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&order); err != nil {
+			return utils.NotFound("Order not found")
+		}
+
+		report, err := safeDelete(c, db, "orders", id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete order"})
+			return deleteError(err, report, "Order not found", "Failed to delete order")
 		}
 
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+		// Give reserved stock back to the catalog; a restored order is no longer reserved
+		if order.StockReserved {
+			actor := adminActor(c)
+			actor.Note = "order deleted"
+			if err := moveStock(db, order, models.StockMovementRelease, 0, -1, actor); err != nil {
+				log.Printf("Failed to release stock of deleted order %s: %v", order.ID.Hex(), err)
+			} else {
+				collection.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"stock_reserved": false}})
+			}
 		}
+		syncOrderClients(db, order)
 
-		return c.JSON(fiber.Map{"message": "Order deleted successfully"})
+		return c.JSON(deleteResult("Order deleted successfully", report))
 	})
 }
 
+// findMyOrder loads the order addressed by :id if it belongs to the signed-in user.
+func findMyOrder(c *fiber.Ctx, db *mongo.Client) (models.Order, error) {
+	var order models.Order
+	userID, err := primitive.ObjectIDFromHex(c.Locals("user_id").(string))
+	if err != nil {
+		return order, err
+	}
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return order, err
+	}
+
+	collection := config.GetCollection(db, "orders")
+	err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id, "user_id": userID})).Decode(&order)
+	return order, err
+}
+
 // About CRUD
 func AboutRoutes(app fiber.Router, db *mongo.Client) {
 	about := app.Group("/about")
@@ -1099,19 +1126,19 @@ func AboutRoutes(app fiber.Router, db *mongo.Client) {
 		err := collection.FindOne(context.TODO(), bson.M{}).Decode(&aboutInfo)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				return c.Status(404).JSON(fiber.Map{"error": "About information not found"})
+				return utils.NotFound("About information not found")
 			}
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch about information"})
+			return utils.Internal("Failed to fetch about information")
 		}
 
 		return c.JSON(aboutInfo)
 	})
 
 	// Create about info
-	about.Post("/", func(c *fiber.Ctx) error {
+	about.Post("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		var aboutInfo models.About
-		if err := c.BodyParser(&aboutInfo); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &aboutInfo); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		aboutInfo.CreatedAt = time.Now()
@@ -1120,21 +1147,21 @@ func AboutRoutes(app fiber.Router, db *mongo.Client) {
 
 		result, err := collection.InsertOne(context.TODO(), aboutInfo)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create about information"})
+			return utils.Internal("Failed to create about information")
 		}
 
 		aboutInfo.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "about", aboutInfo.ID, nil, aboutInfo)
 		return c.Status(201).JSON(aboutInfo)
 	})
 
 	// Update about info
-	about.Put("/", func(c *fiber.Ctx) error {
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	about.Put("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
+		updateData, errs := validation.Decode(c.Body(), models.About{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		delete(updateData, "_id")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "about")
@@ -1142,30 +1169,32 @@ func AboutRoutes(app fiber.Router, db *mongo.Client) {
 
 		// Find and update the first (and should be only) record
 		var aboutInfo models.About
-		err := collection.FindOneAndUpdate(context.TODO(), bson.M{}, update).Decode(&aboutInfo)
+		err := auditedUpdate(c, db, "about", bson.M{}, update)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				return c.Status(404).JSON(fiber.Map{"error": "About information not found"})
+				return utils.NotFound("About information not found")
 			}
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update about information"})
+			return utils.Internal("Failed to update about information")
 		}
 
 		// Get updated record
-		collection.FindOne(context.TODO(), bson.M{"_id": aboutInfo.ID}).Decode(&aboutInfo)
+		collection.FindOne(context.TODO(), bson.M{}).Decode(&aboutInfo)
 		return c.JSON(aboutInfo)
 	})
 
 	// Delete about info
-	about.Delete("/", func(c *fiber.Ctx) error {
+	about.Delete("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "about")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{})
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete about information"})
+		var deleted bson.M
+		err := collection.FindOneAndDelete(context.TODO(), bson.M{}).Decode(&deleted)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("About information not found")
 		}
-
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "About information not found"})
+		if err != nil {
+			return utils.Internal("Failed to delete about information")
 		}
+		id, _ := deleted["_id"].(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionDelete, "about", id, deleted, nil)
 
 		return c.JSON(fiber.Map{"message": "About information deleted successfully"})
 	})
@@ -1192,19 +1221,19 @@ func LinksRoutes(app fiber.Router, db *mongo.Client) {
 		err := collection.FindOne(context.TODO(), bson.M{}).Decode(&linksInfo)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				return c.Status(404).JSON(fiber.Map{"error": "Links information not found"})
+				return utils.NotFound("Links information not found")
 			}
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch links information"})
+			return utils.Internal("Failed to fetch links information")
 		}
 
 		return c.JSON(linksInfo)
 	})
 
 	// Create links
-	links.Post("/", func(c *fiber.Ctx) error {
+	links.Post("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		var linksInfo models.Links
-		if err := c.BodyParser(&linksInfo); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &linksInfo); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		linksInfo.CreatedAt = time.Now()
@@ -1213,21 +1242,21 @@ func LinksRoutes(app fiber.Router, db *mongo.Client) {
 
 		result, err := collection.InsertOne(context.TODO(), linksInfo)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create links information"})
+			return utils.Internal("Failed to create links information")
 		}
 
 		linksInfo.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "links", linksInfo.ID, nil, linksInfo)
 		return c.Status(201).JSON(linksInfo)
 	})
 
 	// Update links
-	links.Put("/", func(c *fiber.Ctx) error {
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	links.Put("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
+		updateData, errs := validation.Decode(c.Body(), models.Links{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		delete(updateData, "_id")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "links")
@@ -1235,30 +1264,32 @@ func LinksRoutes(app fiber.Router, db *mongo.Client) {
 
 		// Find and update the first (and should be only) record
 		var linksInfo models.Links
-		err := collection.FindOneAndUpdate(context.TODO(), bson.M{}, update).Decode(&linksInfo)
+		err := auditedUpdate(c, db, "links", bson.M{}, update)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				return c.Status(404).JSON(fiber.Map{"error": "Links information not found"})
+				return utils.NotFound("Links information not found")
 			}
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update links information"})
+			return utils.Internal("Failed to update links information")
 		}
 
 		// Get updated record
-		collection.FindOne(context.TODO(), bson.M{"_id": linksInfo.ID}).Decode(&linksInfo)
+		collection.FindOne(context.TODO(), bson.M{}).Decode(&linksInfo)
 		return c.JSON(linksInfo)
 	})
 
 	// Delete links
-	links.Delete("/", func(c *fiber.Ctx) error {
+	links.Delete("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "links")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{})
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete links information"})
+		var deleted bson.M
+		err := collection.FindOneAndDelete(context.TODO(), bson.M{}).Decode(&deleted)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Links information not found")
 		}
-
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Links information not found"})
+		if err != nil {
+			return utils.Internal("Failed to delete links information")
 		}
+		id, _ := deleted["_id"].(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionDelete, "links", id, deleted, nil)
 
 		return c.JSON(fiber.Map{"message": "Links information deleted successfully"})
 	})
@@ -1275,18 +1306,26 @@ func DiscountRoutes(app fiber.Router, db *mongo.Client) {
 		err := collection.FindOne(context.TODO(), bson.M{}).Decode(&info)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				return c.Status(404).JSON(fiber.Map{"error": "Discount information not found"})
+				return utils.NotFound("Discount information not found")
 			}
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch discount information"})
+			return utils.Internal("Failed to fetch discount information")
 		}
 		return c.JSON(info)
 	})
 
 	// Create discount info
-	discount.Post("/", func(c *fiber.Ctx) error {
+	discount.Post("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		var info models.Discount
-		if err := c.BodyParser(&info); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &info); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		broken, err := checkReferences(db, referenceChecks(bson.M{"product_id": info.ProductID}, discountReferences)...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
 		}
 
 		info.CreatedAt = time.Now()
@@ -1295,50 +1334,60 @@ func DiscountRoutes(app fiber.Router, db *mongo.Client) {
 
 		result, err := collection.InsertOne(context.TODO(), info)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create discount information"})
+			return utils.Internal("Failed to create discount information")
 		}
 
 		info.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "discount", info.ID, nil, info)
 		return c.Status(201).JSON(info)
 	})
 
 	// Update discount info (single record)
-	discount.Put("/", func(c *fiber.Ctx) error {
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	discount.Put("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
+		updateData, errs := validation.Decode(c.Body(), models.Discount{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		broken, err := checkReferences(db, referenceChecks(updateData, discountReferences)...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
 		}
 
-		delete(updateData, "_id")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "discount")
 		update := bson.M{"$set": updateData}
 
 		var info models.Discount
-		err := collection.FindOneAndUpdate(context.TODO(), bson.M{}, update).Decode(&info)
+		err = auditedUpdate(c, db, "discount", bson.M{}, update)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				return c.Status(404).JSON(fiber.Map{"error": "Discount information not found"})
+				return utils.NotFound("Discount information not found")
 			}
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update discount information"})
+			return utils.Internal("Failed to update discount information")
 		}
 
-		collection.FindOne(context.TODO(), bson.M{"_id": info.ID}).Decode(&info)
+		collection.FindOne(context.TODO(), bson.M{}).Decode(&info)
 		return c.JSON(info)
 	})
 
 	// Delete discount info
-	discount.Delete("/", func(c *fiber.Ctx) error {
+	discount.Delete("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "discount")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{})
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete discount information"})
+		var deleted bson.M
+		err := collection.FindOneAndDelete(context.TODO(), bson.M{}).Decode(&deleted)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Discount information not found")
 		}
-
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Discount information not found"})
+		if err != nil {
+			return utils.Internal("Failed to delete discount information")
 		}
+		id, _ := deleted["_id"].(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionDelete, "discount", id, deleted, nil)
 
 		return c.JSON(fiber.Map{"message": "Discount information deleted successfully"})
 	})
@@ -1355,18 +1404,18 @@ func OfficialPartnerRoutes(app fiber.Router, db *mongo.Client) {
 		err := collection.FindOne(context.TODO(), bson.M{}).Decode(&info)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				return c.Status(404).JSON(fiber.Map{"error": "Official partner information not found"})
+				return utils.NotFound("Official partner information not found")
 			}
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch official partner information"})
+			return utils.Internal("Failed to fetch official partner information")
 		}
 		return c.JSON(info)
 	})
 
 	// Create official partner
-	route.Post("/", func(c *fiber.Ctx) error {
+	route.Post("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		var info models.Official_partner
-		if err := c.BodyParser(&info); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &info); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		info.CreatedAt = time.Now()
@@ -1375,50 +1424,52 @@ func OfficialPartnerRoutes(app fiber.Router, db *mongo.Client) {
 
 		result, err := collection.InsertOne(context.TODO(), info)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create official partner information"})
+			return utils.Internal("Failed to create official partner information")
 		}
 
 		info.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "official_partner", info.ID, nil, info)
 		return c.Status(201).JSON(info)
 	})
 
 	// Update official partner (single record)
-	route.Put("/", func(c *fiber.Ctx) error {
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	route.Put("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
+		updateData, errs := validation.Decode(c.Body(), models.Official_partner{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		delete(updateData, "_id")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "official_partner")
 		update := bson.M{"$set": updateData}
 
 		var info models.Official_partner
-		err := collection.FindOneAndUpdate(context.TODO(), bson.M{}, update).Decode(&info)
+		err := auditedUpdate(c, db, "official_partner", bson.M{}, update)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				return c.Status(404).JSON(fiber.Map{"error": "Official partner information not found"})
+				return utils.NotFound("Official partner information not found")
 			}
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update official partner information"})
+			return utils.Internal("Failed to update official partner information")
 		}
 
-		collection.FindOne(context.TODO(), bson.M{"_id": info.ID}).Decode(&info)
+		collection.FindOne(context.TODO(), bson.M{}).Decode(&info)
 		return c.JSON(info)
 	})
 
 	// Delete official partner
-	route.Delete("/", func(c *fiber.Ctx) error {
+	route.Delete("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "official_partner")
-		result, err := collection.DeleteOne(context.TODO(), bson.M{})
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete official partner information"})
+		var deleted bson.M
+		err := collection.FindOneAndDelete(context.TODO(), bson.M{}).Decode(&deleted)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Official partner information not found")
 		}
-
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Official partner information not found"})
+		if err != nil {
+			return utils.Internal("Failed to delete official partner information")
 		}
+		id, _ := deleted["_id"].(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionDelete, "official_partner", id, deleted, nil)
 
 		return c.JSON(fiber.Map{"message": "Official partner information deleted successfully"})
 	})
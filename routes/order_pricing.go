@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"context"
+	"math"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// orderLineProblem explains why an order line cannot be accepted.
+type orderLineProblem struct {
+	ProductID string `json:"product_id"`
+	Reason    string `json:"reason"` // not_found or insufficient_stock
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// roundMoney rounds an amount to two decimal places.
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// mergeOrderLines validates the requested lines and merges duplicates of the
+// same product. It returns a message describing the first invalid line.
+func mergeOrderLines(lines []models.ProductWithCount) ([]models.ProductWithCount, string) {
+	if len(lines) == 0 {
+		return nil, "Order must contain at least one product"
+	}
+
+	merged := make([]models.ProductWithCount, 0, len(lines))
+	index := make(map[primitive.ObjectID]int, len(lines))
+	for _, line := range lines {
+		if line.ProductID.IsZero() {
+			return nil, "Every product needs a valid product_id"
+		}
+		if line.Count <= 0 {
+			return nil, "Product count must be greater than zero"
+		}
+		if i, ok := index[line.ProductID]; ok {
+			merged[i].Count += line.Count
+			continue
+		}
+		index[line.ProductID] = len(merged)
+		merged = append(merged, models.ProductWithCount{ProductID: line.ProductID, Count: line.Count})
+	}
+	return merged, ""
+}
+
+// priceOrder replaces the order lines with priced snapshots of the current
+// products and fills in the order totals. Product discount, NDC and tax are
+// per-unit amounts; discounts never exceed the price. Lines referring to
+// unknown products or asking for more than is available are returned as
+// problems and leave the order unchanged.
+func priceOrder(db *mongo.Client, order *models.Order) ([]orderLineProblem, error) {
+	ids := make([]primitive.ObjectID, 0, len(order.ProductsWithCount))
+	for _, line := range order.ProductsWithCount {
+		ids = append(ids, line.ProductID)
+	}
+
+	cursor, err := config.GetCollection(db, "products").Find(context.TODO(), notTrashed(bson.M{"_id": bson.M{"$in": ids}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var products []models.Product
+	if err := cursor.All(context.TODO(), &products); err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	var problems []orderLineProblem
+	priced := make([]models.ProductWithCount, 0, len(order.ProductsWithCount))
+	var subtotal, discountTotal, taxTotal float64
+
+	for _, line := range order.ProductsWithCount {
+		product, ok := byID[line.ProductID]
+		if !ok {
+			problems = append(problems, orderLineProblem{ProductID: line.ProductID.Hex(), Reason: "not_found", Requested: line.Count})
+			continue
+		}
+		if product.Available() < line.Count {
+			problems = append(problems, orderLineProblem{
+				ProductID: line.ProductID.Hex(),
+				Reason:    "insufficient_stock",
+				Requested: line.Count,
+				Available: product.Available(),
+			})
+			continue
+		}
+
+		price := product.Price.Float64()
+		discount := math.Min(math.Max(product.Discount.Float64(), 0), price)
+		ndc := product.NDC.Float64()
+		tax := product.Tax.Float64()
+		qty := float64(line.Count)
+
+		l := models.ProductWithCount{
+			ProductID:    product.ID,
+			Count:        line.Count,
+			Name:         product.Name,
+			UnitPrice:    price,
+			UnitDiscount: discount,
+			UnitNDC:      ndc,
+			UnitTax:      tax,
+			LineSubtotal: roundMoney(price * qty),
+			LineDiscount: roundMoney(discount * qty),
+			LineTax:      roundMoney((ndc + tax) * qty),
+		}
+		l.LineTotal = roundMoney(l.LineSubtotal - l.LineDiscount + l.LineTax)
+
+		subtotal += l.LineSubtotal
+		discountTotal += l.LineDiscount
+		taxTotal += l.LineTax
+		priced = append(priced, l)
+	}
+
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	order.ProductsWithCount = priced
+	order.Subtotal = roundMoney(subtotal)
+	order.DiscountTotal = roundMoney(discountTotal)
+	order.TaxTotal = roundMoney(taxTotal)
+	order.TotalAmount = roundMoney(subtotal - discountTotal + taxTotal)
+	return nil, nil
+}
+
+// orderProblemsError is the 422 returned for rejected order lines.
+func orderProblemsError(problems []orderLineProblem) error {
+	return utils.ValidationFailed("Some products are unavailable").With("products", problems)
+}
@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	errOrderNotFound          = errors.New("order not found")
+	errInvalidOrderTransition = errors.New("invalid order status transition")
+	errOrderStatusConflict    = errors.New("order status changed concurrently")
+)
+
+// adminActor describes the admin behind the current request for the order
+// status history and the stock ledger.
+func adminActor(c *fiber.Ctx) models.OrderStatusChange {
+	id, _ := c.Locals("admin_id").(string)
+	name, _ := c.Locals("admin_name").(string)
+	return models.OrderStatusChange{ActorType: "admin", ActorID: id, ActorName: name}
+}
+
+// userStatusActor describes the signed-in user for the order status history.
+func userStatusActor(c *fiber.Ctx) models.OrderStatusChange {
+	id, _ := c.Locals("user_id").(string)
+	phone, _ := c.Locals("user_phone").(string)
+	return models.OrderStatusChange{ActorType: "user", ActorID: id, ActorName: phone}
+}
+
+// forgetUserActor removes the actor name, the user's phone number, from the
+// order status histories and stock ledger entries the user is recorded in.
+func forgetUserActor(ctx context.Context, db *mongo.Client, userID, phone string) error {
+	actor := bson.A{bson.M{"actor_type": "user", "actor_id": userID}}
+	entry := bson.A{bson.M{"entry.actor_type": "user", "entry.actor_id": userID}}
+	if phone != "" {
+		actor = append(actor, bson.M{"actor_name": phone})
+		entry = append(entry, bson.M{"entry.actor_name": phone})
+	}
+
+	_, err := config.GetCollection(db, "orders").UpdateMany(ctx,
+		bson.M{"status_history": bson.M{"$elemMatch": bson.M{"$or": actor}}},
+		bson.M{"$unset": bson.M{"status_history.$[entry].actor_name": ""}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"$or": entry}}}),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = config.GetCollection(db, "stock_movements").UpdateMany(ctx,
+		bson.M{"$or": actor},
+		bson.M{"$unset": bson.M{"actor_name": ""}},
+	)
+	return err
+}
+
+// statusFilter matches orders currently in status. Orders without a status are
+// treated as pending.
+func statusFilter(status string) interface{} {
+	if status == models.OrderStatusPending {
+		return bson.M{"$in": bson.A{models.OrderStatusPending, "", nil}}
+	}
+	return status
+}
+
+// changeOrderStatus moves an order to change.To if the transition is allowed
+// and appends change to its status history. When onlyFrom is given, the order
+// must currently be in one of those statuses. The update only applies while
+// the order is still in the status it was read with, so concurrent changes
+// fail with errOrderStatusConflict instead of skipping a step. The change is
+// recorded in the audit log as made by the request's actor.
+func changeOrderStatus(c *fiber.Ctx, db *mongo.Client, id primitive.ObjectID, change models.OrderStatusChange, onlyFrom ...string) (models.Order, error) {
+	collection := config.GetCollection(db, "orders")
+
+	var order models.Order
+	if err := collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&order); err != nil {
+		return order, errOrderNotFound
+	}
+
+	change.From = order.CurrentStatus()
+	if !models.CanTransitionOrderStatus(change.From, change.To) {
+		return order, errInvalidOrderTransition
+	}
+	if len(onlyFrom) > 0 {
+		allowed := false
+		for _, status := range onlyFrom {
+			allowed = allowed || status == change.From
+		}
+		if !allowed {
+			return order, errInvalidOrderTransition
+		}
+	}
+	change.ChangedAt = time.Now()
+
+	set := bson.M{"status": change.To, "updated_at": change.ChangedAt}
+
+	// Move stock first so an order never reaches a status its stock does not back
+	effect := stockEffectFor(order, change.To)
+	if effect != nil {
+		if err := moveStock(db, order, effect.movementType, effect.countMul, effect.reservedMul, change); err != nil {
+			return order, err
+		}
+		set["stock_reserved"] = effect.reserved
+	}
+
+	var updated models.Order
+	err := collection.FindOneAndUpdate(context.TODO(),
+		notTrashed(bson.M{"_id": id, "status": statusFilter(change.From)}),
+		bson.M{
+			"$set":  set,
+			"$push": bson.M{"status_history": change},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if effect != nil {
+			revertStock(db, order, effect, change)
+		}
+		if err == mongo.ErrNoDocuments {
+			return order, errOrderStatusConflict
+		}
+		return order, err
+	}
+	recordAudit(c, db, models.AuditActionUpdate, "orders", id, order, updated)
+	syncOrderClients(db, updated)
+	return updated, nil
+}
+
+// orderStatusError maps an error from changeOrderStatus to its API error.
+func orderStatusError(order models.Order, to string, err error) error {
+	if stockErr, ok := err.(*stockError); ok {
+		return utils.Conflict("Not enough stock to move the order to "+to).With("products", stockErr.Problems)
+	}
+
+	switch err {
+	case errOrderNotFound:
+		return utils.NotFound("Order not found")
+	case errInvalidOrderTransition:
+		return utils.Conflict("Cannot change order status from "+order.CurrentStatus()+" to "+to).
+			With("current_status", order.CurrentStatus()).
+			With("allowed", models.NextOrderStatuses(order.CurrentStatus()))
+	case errOrderStatusConflict:
+		return utils.Conflict("Order status was changed by another request, please retry")
+	default:
+		return utils.Internal("Failed to update order status")
+	}
+}
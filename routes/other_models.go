@@ -2,11 +2,14 @@ package routes
 
 import (
 	"context"
-	"strconv"
 	"time"
 
 	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
 	"fiber-ecommerce/models"
+	"fiber-ecommerce/query"
+	"fiber-ecommerce/utils"
+	"fiber-ecommerce/validation"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -19,60 +22,69 @@ import (
 // Generic CRUD helper function
 func genericCRUD(app fiber.Router, db *mongo.Client, routeName, collectionName string, model interface{}) {
 	route := app.Group("/" + routeName)
+	registerTrash(routeName, collectionName, catalogRoles)
+	spec := query.NewSpec(model)
 
 	// Get all
 	route.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, collectionName)
 		
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		skip := (page - 1) * limit
+		list, err := spec.Parse(c)
+		if err != nil {
+			return err
+		}
+		filter := list.Apply(notTrashed(bson.M{}))
 
-		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
-		
-		cursor, err := collection.Find(context.TODO(), bson.M{}, opts)
+		cursor, err := collection.Find(context.TODO(), list.Seek(filter), list.FindOptions())
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch " + routeName})
+			return utils.Internal("Failed to fetch " + routeName)
 		}
 		defer cursor.Close(context.TODO())
 
 		var results []bson.M
 		if err = cursor.All(context.TODO(), &results); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode " + routeName})
+			return utils.Internal("Failed to decode " + routeName)
 		}
+		results, next := query.Page(list, results)
 
-		total, _ := collection.CountDocuments(context.TODO(), bson.M{})
+		data, err := list.Select(results)
+		if err != nil {
+			return utils.Internal("Failed to encode " + routeName)
+		}
 
-		return c.JSON(fiber.Map{
-			"data":  results,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		})
+		return c.JSON(list.Response(data, next, collection, filter))
 	})
 
 	// Get single
 	route.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, collectionName)
 		var result bson.M
-		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&result)
+		err = collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&result)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": routeName + " not found"})
+			return utils.NotFound(routeName + " not found")
 		}
 
 		return c.JSON(result)
 	})
 
 	// Create
-	route.Post("/", func(c *fiber.Ctx) error {
-		var data bson.M
-		if err := c.BodyParser(&data); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	route.Post("/", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
+		data, errs := validation.Decode(c.Body(), model, false)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		broken, err := checkReferences(db, referenceChecks(data, collectionReferences[collectionName])...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
 		}
 
 		data["created_at"] = time.Now()
@@ -81,63 +93,64 @@ func genericCRUD(app fiber.Router, db *mongo.Client, routeName, collectionName s
 		collection := config.GetCollection(db, collectionName)
 		result, err := collection.InsertOne(context.TODO(), data)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create " + routeName})
+			return utils.Internal("Failed to create " + routeName)
 		}
 
 		data["_id"] = result.InsertedID
+		recordAudit(c, db, models.AuditActionCreate, collectionName, result.InsertedID.(primitive.ObjectID), nil, data)
 		return c.Status(201).JSON(data)
 	})
 
 	// Update
-	route.Put("/:id", func(c *fiber.Ctx) error {
+	route.Put("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		updateData, errs := validation.Decode(c.Body(), model, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
-
-		delete(updateData, "_id")
 		updateData["updated_at"] = time.Now()
 
+		broken, err := checkReferences(db, referenceChecks(updateData, collectionReferences[collectionName])...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return brokenReferencesError(broken)
+		}
+
 		collection := config.GetCollection(db, collectionName)
 		update := bson.M{"$set": updateData}
 		
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update " + routeName})
+		err = auditedUpdate(c, db, collectionName, notTrashed(bson.M{"_id": id}), update)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound(routeName + " not found")
 		}
-
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": routeName + " not found"})
+		if err != nil {
+			return utils.Internal("Failed to update " + routeName)
 		}
 
 		var updatedDoc bson.M
-		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&updatedDoc)
+		collection.FindOne(context.TODO(), notTrashed(bson.M{"_id": id})).Decode(&updatedDoc)
 		return c.JSON(updatedDoc)
 	})
 
 	// Delete
-	route.Delete("/:id", func(c *fiber.Ctx) error {
+	route.Delete("/:id", middleware.AdminJWTMiddleware(catalogRoles...), func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		collection := config.GetCollection(db, collectionName)
-		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+		report, err := safeDelete(c, db, collectionName, id)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete " + routeName})
-		}
-
-		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": routeName + " not found"})
+			return deleteError(err, report, routeName+" not found", "Failed to delete "+routeName)
 		}
 
-		return c.JSON(fiber.Map{"message": routeName + " deleted successfully"})
+		return c.JSON(deleteResult(routeName+" deleted successfully", report))
 	})
 }
 
@@ -163,27 +176,25 @@ func PartnerRoutes(app fiber.Router, db *mongo.Client) {
 
 // Admin CRUD with password hashing
 func AdminRoutes(app fiber.Router, db *mongo.Client) {
-	admins := app.Group("/admins")
+	admins := app.Group("/admins", middleware.AdminJWTMiddleware(superAdminRoles...))
 
 	// Get all admins
 	admins.Get("/", func(c *fiber.Ctx) error {
 		collection := config.GetCollection(db, "admins")
 		
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		limit, _ := strconv.Atoi(c.Query("limit", "10"))
-		skip := (page - 1) * limit
+		page, limit, skip := utils.ParsePaginationParams(c)
 
 		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
 		
 		cursor, err := collection.Find(context.TODO(), bson.M{}, opts)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch admins"})
+			return utils.Internal("Failed to fetch admins")
 		}
 		defer cursor.Close(context.TODO())
 
 		var admins []models.Admin
 		if err = cursor.All(context.TODO(), &admins); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode admins"})
+			return utils.Internal("Failed to decode admins")
 		}
 
 		// Remove passwords from response
@@ -205,14 +216,14 @@ func AdminRoutes(app fiber.Router, db *mongo.Client) {
 	admins.Get("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "admins")
 		var admin models.Admin
 		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&admin)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Admin not found"})
+			return utils.NotFound("Admin not found")
 		}
 
 		admin.Password = "" // Don't return password
@@ -222,27 +233,38 @@ func AdminRoutes(app fiber.Router, db *mongo.Client) {
 	// Create admin
 	admins.Post("/", func(c *fiber.Ctx) error {
 		var admin models.Admin
-		if err := c.BodyParser(&admin); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &admin); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		if admin.Role == "" {
+			admin.Role = models.RoleContentEditor
 		}
 
 		// Hash password
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.DefaultCost)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to hash password"})
+			return utils.Internal("Failed to hash password")
 		}
 
 		admin.Password = string(hashedPassword)
+		admin.DeactivatedAt = nil
 		admin.CreatedAt = time.Now()
 		admin.UpdatedAt = time.Now()
 		
 		collection := config.GetCollection(db, "admins")
+
+		if taken, _ := collection.CountDocuments(context.TODO(), bson.M{"name": admin.Name}); taken > 0 {
+			return utils.Conflict("Admin with this name already exists")
+		}
+
 		result, err := collection.InsertOne(context.TODO(), admin)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create admin"})
+			return utils.Internal("Failed to create admin")
 		}
 
 		admin.ID = result.InsertedID.(primitive.ObjectID)
+		recordAudit(c, db, models.AuditActionCreate, "admins", admin.ID, nil, admin)
 		admin.Password = "" // Don't return password
 		return c.Status(201).JSON(admin)
 	})
@@ -251,36 +273,136 @@ func AdminRoutes(app fiber.Router, db *mongo.Client) {
 	admins.Put("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		// Activation is managed by the dedicated activate/deactivate endpoints
+		updateData, errs := validation.Decode(c.Body(), models.Admin{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
 		// Hash password if provided
-		if password, exists := updateData["password"]; exists && password != "" {
-			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password.(string)), bcrypt.DefaultCost)
+		if password, exists := updateData["password"].(string); exists {
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 			if err != nil {
-				return c.Status(500).JSON(fiber.Map{"error": "Failed to hash password"})
+				return utils.Internal("Failed to hash password")
 			}
 			updateData["password"] = string(hashedPassword)
 		}
 
-		delete(updateData, "_id")
+		collection := config.GetCollection(db, "admins")
+
+		var existing models.Admin
+		if err := collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&existing); err != nil {
+			return utils.NotFound("Admin not found")
+		}
+
+		if roleStr, exists := updateData["role"].(string); exists {
+			if roleStr == "" {
+				return validation.Failed(validation.Error("role", "required", "Field is required"))
+			}
+			if roleStr != models.RoleSuperAdmin {
+				last, err := isLastActiveSuperAdmin(db, existing)
+				if err != nil {
+					return utils.Internal("Failed to update admin")
+				}
+				if last {
+					return utils.Conflict("Cannot change the role of the last active superadmin")
+				}
+			}
+		}
+
+		if name, exists := updateData["name"]; exists {
+			taken, _ := collection.CountDocuments(context.TODO(), bson.M{"name": name, "_id": bson.M{"$ne": id}})
+			if taken > 0 {
+				return utils.Conflict("Admin with this name already exists")
+			}
+		}
+
 		updateData["updated_at"] = time.Now()
 
-		collection := config.GetCollection(db, "admins")
 		update := bson.M{"$set": updateData}
 		
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
+		err = auditedUpdate(c, db, "admins", bson.M{"_id": id}, update)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Admin not found")
+		}
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update admin"})
+			return utils.Internal("Failed to update admin")
 		}
 
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Admin not found"})
+		// Role or password changes take effect on the next login
+		_, roleChanged := updateData["role"]
+		_, passwordChanged := updateData["password"]
+		if roleChanged || passwordChanged {
+			revokeAllSessions(db, "admin", id)
+		}
+
+		var admin models.Admin
+		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&admin)
+		admin.Password = "" // Don't return password
+		return c.JSON(admin)
+	})
+
+	// Deactivate admin
+	admins.Post("/:id/deactivate", func(c *fiber.Ctx) error {
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.BadRequest("Invalid ID")
+		}
+
+		if adminID, _ := c.Locals("admin_id").(string); adminID == id.Hex() {
+			return utils.Conflict("You cannot deactivate your own account")
+		}
+
+		collection := config.GetCollection(db, "admins")
+		var admin models.Admin
+		if err := collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&admin); err != nil {
+			return utils.NotFound("Admin not found")
+		}
+
+		last, err := isLastActiveSuperAdmin(db, admin)
+		if err != nil {
+			return utils.Internal("Failed to deactivate admin")
+		}
+		if last {
+			return utils.Conflict("Cannot deactivate the last active superadmin")
+		}
+
+		now := time.Now()
+		err = auditedUpdate(c, db, "admins", bson.M{"_id": id}, bson.M{"$set": bson.M{
+			"deactivated_at": now,
+			"updated_at":     now,
+		}})
+		if err != nil {
+			return utils.Internal("Failed to deactivate admin")
+		}
+
+		revokeAllSessions(db, "admin", id)
+
+		collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&admin)
+		admin.Password = "" // Don't return password
+		return c.JSON(admin)
+	})
+
+	// Reactivate admin
+	admins.Post("/:id/activate", func(c *fiber.Ctx) error {
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.BadRequest("Invalid ID")
+		}
+
+		collection := config.GetCollection(db, "admins")
+		err = auditedUpdate(c, db, "admins", bson.M{"_id": id}, bson.M{
+			"$unset": bson.M{"deactivated_at": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		})
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Admin not found")
+		}
+		if err != nil {
+			return utils.Internal("Failed to activate admin")
 		}
 
 		var admin models.Admin
@@ -293,18 +415,35 @@ func AdminRoutes(app fiber.Router, db *mongo.Client) {
 	admins.Delete("/:id", func(c *fiber.Ctx) error {
 		id, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+			return utils.BadRequest("Invalid ID")
 		}
 
 		collection := config.GetCollection(db, "admins")
+
+		var admin models.Admin
+		if err := collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&admin); err != nil {
+			return utils.NotFound("Admin not found")
+		}
+
+		last, err := isLastActiveSuperAdmin(db, admin)
+		if err != nil {
+			return utils.Internal("Failed to delete admin")
+		}
+		if last {
+			return utils.Conflict("Cannot delete the last active superadmin")
+		}
+
 		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete admin"})
+			return utils.Internal("Failed to delete admin")
 		}
 
 		if result.DeletedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "Admin not found"})
+			return utils.NotFound("Admin not found")
 		}
+		recordAudit(c, db, models.AuditActionDelete, "admins", id, admin, nil)
+
+		revokeAllSessions(db, "admin", id)
 
 		return c.JSON(fiber.Map{"message": "Admin deleted successfully"})
 	})
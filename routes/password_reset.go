@@ -0,0 +1,232 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/notify"
+	"fiber-ecommerce/utils"
+	"fiber-ecommerce/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetTTL = 30 * time.Minute
+	// passwordResetCooldown is the minimum delay between two tokens for the same account.
+	passwordResetCooldown = time.Minute
+)
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+// passwordResetAccepted is returned whether or not a token was actually sent,
+// so the endpoints cannot be used to discover registered accounts.
+const passwordResetAccepted = "If the account exists, password reset instructions have been sent"
+
+// ensurePasswordResetIndexes creates the token lookup and TTL indexes.
+func ensurePasswordResetIndexes(db *mongo.Client) {
+	collection := config.GetCollection(db, "password_resets")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "subject_id", Value: 1}, {Key: "subject_type", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Printf("Failed to create password reset indexes: %v", err)
+	}
+}
+
+// issuePasswordReset replaces any pending reset of the subject with a new token
+// and delivers it to the recipient. Tokens requested within the cooldown are
+// silently skipped.
+func issuePasswordReset(db *mongo.Client, notifier notify.Notifier, subjectType string, subjectID primitive.ObjectID, to notify.Recipient) error {
+	collection := config.GetCollection(db, "password_resets")
+	now := time.Now()
+
+	recent, err := collection.CountDocuments(context.TODO(), bson.M{
+		"subject_type": subjectType,
+		"subject_id":   subjectID,
+		"used_at":      nil,
+		"created_at":   bson.M{"$gt": now.Add(-passwordResetCooldown)},
+	})
+	if err == nil && recent > 0 {
+		return nil
+	}
+
+	token, err := newRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	// Only the latest token stays valid
+	collection.DeleteMany(context.TODO(), bson.M{"subject_type": subjectType, "subject_id": subjectID, "used_at": nil})
+
+	result, err := collection.InsertOne(context.TODO(), models.PasswordReset{
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		TokenHash:   hashToken(token),
+		ExpiresAt:   now.Add(passwordResetTTL),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Your password reset token is %s. It expires in %d minutes.", token, int(passwordResetTTL.Minutes()))
+	if err := notifier.Notify(to, "Password reset", message); err != nil {
+		collection.DeleteOne(context.TODO(), bson.M{"_id": result.InsertedID})
+		return err
+	}
+	return nil
+}
+
+// consumePasswordReset marks the token as used and returns it. Each token can
+// be consumed only once.
+func consumePasswordReset(db *mongo.Client, token, subjectType string) (models.PasswordReset, error) {
+	collection := config.GetCollection(db, "password_resets")
+	now := time.Now()
+
+	var reset models.PasswordReset
+	err := collection.FindOneAndUpdate(context.TODO(),
+		bson.M{
+			"token_hash":   hashToken(token),
+			"subject_type": subjectType,
+			"used_at":      nil,
+			"expires_at":   bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&reset)
+	if err != nil {
+		return reset, errInvalidResetToken
+	}
+	return reset, nil
+}
+
+// resetPasswordHandler completes a reset for accounts stored in collectionName.
+// identifierField names the login identifier whose lockout is cleared afterwards.
+func resetPasswordHandler(db *mongo.Client, subjectType, collectionName, identifierField string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req models.ResetPasswordRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		if ok, msg := utils.IsStrongPassword(req.NewPassword); !ok {
+			return validation.Failed(validation.Error("new_password", "weak_password", msg))
+		}
+
+		reset, err := consumePasswordReset(db, req.Token, subjectType)
+		if err != nil {
+			return utils.BadRequest("Invalid or expired reset token")
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return utils.Internal("Failed to hash password")
+		}
+
+		collection := config.GetCollection(db, collectionName)
+		var account bson.M
+		err = collection.FindOneAndUpdate(context.TODO(), bson.M{"_id": reset.SubjectID}, bson.M{"$set": bson.M{
+			"password":   string(hashedPassword),
+			"updated_at": time.Now(),
+		}}).Decode(&account)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return utils.NotFound("Account not found")
+			}
+			return utils.Internal("Failed to update password")
+		}
+
+		// Sign out every device that used the old password and lift any lockout
+		revokeAllSessions(db, subjectType, reset.SubjectID)
+		if identifier, ok := account[identifierField].(string); ok {
+			clearLoginFailures(db, accountThrottle(subjectType, identifier))
+		}
+
+		return c.JSON(fiber.Map{"message": "Password has been reset. Please log in with your new password."})
+	}
+}
+
+func PasswordResetRoutes(app fiber.Router, db *mongo.Client, notifier notify.Notifier) {
+	ensurePasswordResetIndexes(db)
+
+	auth := app.Group("/auth")
+	adminAuth := app.Group("/admin")
+
+	// User Forgot Password - send a reset token by SMS or email
+	auth.Post("/forgot-password", func(c *fiber.Ctx) error {
+		var req models.ForgotPasswordRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		var filter bson.M
+		switch {
+		case req.Phone != "":
+			filter = bson.M{"phone": req.Phone}
+		case req.Email != "":
+			filter = bson.M{"email": req.Email}
+		default:
+			return validation.Failed(validation.Error("phone", "required", "Phone or email is required"))
+		}
+
+		var user models.User
+		if err := config.GetCollection(db, "users").FindOne(context.TODO(), filter).Decode(&user); err != nil || !user.IsActive {
+			return c.JSON(fiber.Map{"message": passwordResetAccepted})
+		}
+
+		to := notify.Recipient{Phone: user.Phone, Email: user.Email}
+		if err := issuePasswordReset(db, notifier, "user", user.ID, to); err != nil {
+			log.Printf("Failed to issue password reset for user %s: %v", user.ID.Hex(), err)
+			return utils.UpstreamFailed("Failed to send reset instructions")
+		}
+
+		return c.JSON(fiber.Map{"message": passwordResetAccepted})
+	})
+
+	// User Reset Password - set a new password with a reset token
+	auth.Post("/reset-password", resetPasswordHandler(db, "user", "users", "phone"))
+
+	// Admin Forgot Password - send a reset token to the admin's phone or email
+	adminAuth.Post("/forgot-password", func(c *fiber.Ctx) error {
+		var req models.ForgotPasswordRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		if req.Name == "" {
+			return validation.Failed(validation.Error("name", "required", "Field is required"))
+		}
+
+		var admin models.Admin
+		if err := config.GetCollection(db, "admins").FindOne(context.TODO(), bson.M{"name": req.Name}).Decode(&admin); err != nil || !admin.IsActive() {
+			return c.JSON(fiber.Map{"message": passwordResetAccepted})
+		}
+
+		to := notify.Recipient{Phone: admin.Phone, Email: admin.Email}
+		if err := issuePasswordReset(db, notifier, "admin", admin.ID, to); err != nil {
+			// Admins without contact details have to ask a superadmin instead
+			log.Printf("Failed to issue password reset for admin %s: %v", admin.ID.Hex(), err)
+			if !errors.Is(err, notify.ErrNoChannel) {
+				return utils.UpstreamFailed("Failed to send reset instructions")
+			}
+		}
+
+		return c.JSON(fiber.Map{"message": passwordResetAccepted})
+	})
+
+	// Admin Reset Password - set a new password with a reset token
+	adminAuth.Post("/reset-password", resetPasswordHandler(db, "admin", "admins", "name"))
+}
@@ -0,0 +1,251 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/notify"
+	"fiber-ecommerce/utils"
+	"fiber-ecommerce/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	otpLength      = 6
+	otpTTL         = 5 * time.Minute
+	otpMaxAttempts = 5
+	// otpResendCooldown is the minimum delay between two codes for the same phone.
+	otpResendCooldown = time.Minute
+)
+
+// otpRequestAccepted is returned whether or not a code was actually sent, so
+// the endpoint cannot be used to discover registered phone numbers.
+const otpRequestAccepted = "If the phone number is registered, a code has been sent"
+
+// ensurePhoneOTPIndexes creates the lookup and TTL indexes.
+func ensurePhoneOTPIndexes(db *mongo.Client) {
+	collection := config.GetCollection(db, "phone_otps")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "phone", Value: 1}, {Key: "purpose", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Printf("Failed to create phone OTP indexes: %v", err)
+	}
+}
+
+// newOTPCode returns a random numeric code of otpLength digits.
+func newOTPCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(math.Pow10(otpLength))))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", otpLength, n.Int64()), nil
+}
+
+// checkOTP validates the code for phone and purpose. Every check counts
+// towards otpMaxAttempts before the code is compared, in one atomic update, so
+// parallel guesses cannot get past the limit. A matching code is consumed by
+// a delete only one request can win; after the last attempt the code is
+// discarded and a new one has to be requested.
+func checkOTP(db *mongo.Client, phone, purpose, code string) bool {
+	collection := config.GetCollection(db, "phone_otps")
+	filter := bson.M{
+		"phone":      phone,
+		"purpose":    purpose,
+		"expires_at": bson.M{"$gt": time.Now()},
+		"attempts":   bson.M{"$lt": otpMaxAttempts},
+	}
+
+	var otp models.PhoneOTP
+	err := collection.FindOneAndUpdate(context.TODO(), filter,
+		bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&otp)
+	if err != nil {
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(otp.CodeHash), []byte(hashToken(code))) == 1 {
+		result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": otp.ID, "code_hash": otp.CodeHash})
+		return err == nil && result.DeletedCount == 1
+	}
+
+	if otp.Attempts >= otpMaxAttempts {
+		collection.DeleteOne(context.TODO(), bson.M{"_id": otp.ID, "code_hash": otp.CodeHash})
+	}
+	return false
+}
+
+func PhoneOTPRoutes(app fiber.Router, db *mongo.Client, sms notify.SMSSender) {
+	ensurePhoneOTPIndexes(db)
+
+	otp := app.Group("/auth/otp")
+
+	// Request a code - purpose "verify" confirms the phone, "login" signs in without a password
+	otp.Post("/request", func(c *fiber.Ctx) error {
+		var req models.OTPRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		if req.Purpose == "" {
+			req.Purpose = models.OTPPurposeVerify
+		}
+
+		collection := config.GetCollection(db, "phone_otps")
+
+		// Enforce the resend cooldown before doing anything else
+		var existing models.PhoneOTP
+		err := collection.FindOne(context.TODO(), bson.M{"phone": req.Phone, "purpose": req.Purpose}).Decode(&existing)
+		if err == nil && existing.ExpiresAt.After(time.Now()) {
+			if wait := time.Until(existing.CreatedAt.Add(otpResendCooldown)); wait > 0 {
+				seconds := int(math.Ceil(wait.Seconds()))
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+				return utils.TooManyRequests("A code was sent recently. Please wait before requesting another.").
+					With("retry_after_seconds", seconds)
+			}
+		}
+
+		var user models.User
+		if err := config.GetCollection(db, "users").FindOne(context.TODO(), bson.M{"phone": req.Phone}).Decode(&user); err != nil || !user.IsActive {
+			return c.JSON(fiber.Map{"message": otpRequestAccepted})
+		}
+
+		code, err := newOTPCode()
+		if err != nil {
+			return utils.Internal("Failed to generate code")
+		}
+
+		// Replace any previous code for the same phone and purpose
+		now := time.Now()
+		_, err = collection.ReplaceOne(context.TODO(),
+			bson.M{"phone": req.Phone, "purpose": req.Purpose},
+			models.PhoneOTP{
+				Phone:     req.Phone,
+				Purpose:   req.Purpose,
+				CodeHash:  hashToken(code),
+				ExpiresAt: now.Add(otpTTL),
+				CreatedAt: now,
+			},
+			options.Replace().SetUpsert(true),
+		)
+		if err != nil {
+			return utils.Internal("Failed to store code")
+		}
+
+		message := fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code, int(otpTTL.Minutes()))
+		if err := sms.SendSMS(req.Phone, message); err != nil {
+			log.Printf("Failed to send OTP to %s: %v", req.Phone, err)
+			collection.DeleteOne(context.TODO(), bson.M{"phone": req.Phone, "purpose": req.Purpose})
+			return utils.UpstreamFailed("Failed to send code")
+		}
+
+		return c.JSON(fiber.Map{"message": otpRequestAccepted})
+	})
+
+	// Verify a code and mark the phone number as verified
+	otp.Post("/verify", func(c *fiber.Ctx) error {
+		var req models.OTPVerifyRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		if !checkOTP(db, req.Phone, models.OTPPurposeVerify, req.Code) {
+			return utils.Unauthorized("Invalid or expired code")
+		}
+
+		result, err := config.GetCollection(db, "users").UpdateOne(context.TODO(),
+			bson.M{"phone": req.Phone},
+			bson.M{"$set": bson.M{"phone_verified": true, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			return utils.Internal("Failed to verify phone")
+		}
+		if result.MatchedCount == 0 {
+			return utils.NotFound("User not found")
+		}
+
+		return c.JSON(fiber.Map{"message": "Phone number verified", "phone_verified": true})
+	})
+
+	// Passwordless login with a code requested for purpose "login"
+	otp.Post("/login", func(c *fiber.Ctx) error {
+		var req models.OTPVerifyRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		// OTP logins share the lockout counters of password logins
+		accountKey, ipKey := accountThrottle("user", req.Phone), ipThrottle(c.IP())
+		if lockout := loginLockedFor(db, accountKey, ipKey); lockout > 0 {
+			recordLoginAttempt(db, c, "user", req.Phone, nil, false, models.LoginReasonLockedOut)
+			return tooManyLoginAttempts(c, lockout)
+		}
+
+		if !checkOTP(db, req.Phone, models.OTPPurposeLogin, req.Code) {
+			registerLoginFailure(db, accountKey, ipKey)
+			recordLoginAttempt(db, c, "user", req.Phone, nil, false, models.LoginReasonInvalidOTP)
+			return utils.Unauthorized("Invalid or expired code")
+		}
+
+		collection := config.GetCollection(db, "users")
+
+		var user models.User
+		if err := collection.FindOne(context.TODO(), bson.M{"phone": req.Phone}).Decode(&user); err != nil {
+			recordLoginAttempt(db, c, "user", req.Phone, nil, false, models.LoginReasonUnknownAccount)
+			return utils.Unauthorized("Invalid credentials")
+		}
+
+		if !user.IsActive {
+			recordLoginAttempt(db, c, "user", req.Phone, &user.ID, false, models.LoginReasonAccountDeactivated)
+			return utils.Unauthorized("Account is deactivated. Please contact administrator.")
+		}
+
+		clearLoginFailures(db, accountKey)
+		recordLoginAttempt(db, c, "user", req.Phone, &user.ID, true, models.LoginReasonSuccess)
+
+		// Receiving the code proves ownership of the phone as well
+		now := time.Now()
+		collection.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{
+			"$set": bson.M{
+				"phone_verified": true,
+				"last_login":     &now,
+				"updated_at":     now,
+			},
+		})
+		user.PhoneVerified = true
+		user.LastLogin = &now
+
+		user.Password = "" // Don't return password
+
+		session, refreshToken, err := createSession(db, c, "user", user.ID)
+		if err != nil {
+			return utils.Internal("Failed to create session")
+		}
+
+		token, err := generateUserJWT(user.ID.Hex(), user.Phone, session.ID.Hex())
+		if err != nil {
+			return utils.Internal("Failed to generate token")
+		}
+
+		return c.JSON(models.UserAuthResponse{
+			Token:        token,
+			RefreshToken: refreshToken,
+			User:         user,
+		})
+	})
+}
@@ -0,0 +1,256 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// reference declares that a document field holds the ID of a document in
+// another collection. OnDelete says what a cascading delete of the referenced
+// document does to the referencing documents.
+type reference struct {
+	Field      string
+	Collection string
+	OnDelete   string
+	// Key is the field of the referenced document the value matches when it
+	// is not the _id, e.g. the unique_id of a top-category sort.
+	Key string
+	// Hex marks references stored as hex strings instead of ObjectIDs.
+	Hex bool
+}
+
+const (
+	onDeleteCascade  = "delete"   // delete the referencing documents too
+	onDeleteUnset    = "unset"    // remove the reference, keep the document
+	onDeleteRestrict = "restrict" // never cascade; the reference must be reassigned
+)
+
+// referenceCheck is a single reference value to verify.
+type referenceCheck struct {
+	Field      string
+	Collection string
+	Key        string // see reference.Key
+	Value      interface{}
+}
+
+// brokenReference describes a reference that does not resolve.
+type brokenReference struct {
+	Field      string `json:"field"`
+	Value      string `json:"value"`
+	Collection string `json:"collection"`
+	Reason     string `json:"reason"` // invalid_id or not_found
+}
+
+var (
+	categoryReferences = []reference{
+		{Field: "top_category_id", Collection: "topcategories", OnDelete: onDeleteCascade},
+	}
+	productReferences = []reference{
+		{Field: "category_id", Collection: "categories", OnDelete: onDeleteUnset},
+		{Field: "top_category_id", Collection: "topcategories", OnDelete: onDeleteUnset},
+	}
+	contractReferences = []reference{
+		{Field: "client_id", Collection: "clients", OnDelete: onDeleteCascade},
+		{Field: "counterparty_id", Collection: "counterparties", OnDelete: onDeleteCascade},
+		{Field: "company_id", Collection: "companies", OnDelete: onDeleteCascade},
+		{Field: "funnel_id", Collection: "funnels", OnDelete: onDeleteUnset},
+	}
+	orderReferences = []reference{
+		{Field: "client_id", Collection: "clients", OnDelete: onDeleteUnset},
+	}
+	// The discount stays when its product is deleted and only loses product_id
+	discountReferences = []reference{
+		{Field: "product_id", Collection: "products", OnDelete: onDeleteUnset, Hex: true},
+	}
+	// Order and contract lines keep their product snapshot and stock history,
+	// so products they use are never deleted by a cascade.
+	productLineReference = reference{Field: "products.product_id", Collection: "products", OnDelete: onDeleteRestrict}
+)
+
+// collectionReferences lists the references of collections served by genericCRUD.
+var collectionReferences = map[string][]reference{
+	"banners": {
+		{Field: "top_category_id", Collection: "topcategories", OnDelete: onDeleteUnset},
+		{Field: "category_id", Collection: "categories", OnDelete: onDeleteUnset},
+		{Field: "product_id", Collection: "products", OnDelete: onDeleteUnset},
+	},
+	"banner_sorts": {
+		{Field: "banner_id", Collection: "banners", OnDelete: onDeleteCascade},
+		{Field: "top_category_id", Collection: "topcategories", OnDelete: onDeleteUnset},
+		{Field: "category_id", Collection: "categories", OnDelete: onDeleteUnset},
+		{Field: "product_id", Collection: "products", OnDelete: onDeleteUnset},
+	},
+	"top_category_sorts": {
+		{Field: "top_category_id", Collection: "topcategories", OnDelete: onDeleteCascade},
+	},
+	"category_sorts": {
+		{Field: "category_id", Collection: "categories", OnDelete: onDeleteCascade},
+		{Field: "top_category_sort_id", Collection: "top_category_sorts", OnDelete: onDeleteCascade, Key: "unique_id"},
+	},
+	"select_reviews": {
+		{Field: "review_id", Collection: "reviews", OnDelete: onDeleteCascade},
+	},
+}
+
+// referenceSchema lists the references held by the documents of every
+// collection. It drives the dependency checks when a document is deleted.
+var referenceSchema = func() map[string][]reference {
+	schema := map[string][]reference{
+		"categories": categoryReferences,
+		"products":   productReferences,
+		"contracts":  append(append([]reference{}, contractReferences...), productLineReference),
+		"orders":     append(append([]reference{}, orderReferences...), productLineReference),
+		"discount":   discountReferences,
+	}
+	for collection, refs := range collectionReferences {
+		schema[collection] = refs
+	}
+	return schema
+}()
+
+// referenceChecks collects the checks for the references present in a request
+// map. Valid hex strings are replaced by ObjectIDs in place so references are
+// always stored with the type the models expect, unless the model keeps them
+// as strings (Hex) or they match a field other than the _id (Key).
+func referenceChecks(data bson.M, refs []reference) []referenceCheck {
+	var checks []referenceCheck
+	for _, ref := range refs {
+		value, ok := data[ref.Field]
+		if !ok {
+			continue
+		}
+		if hex, isString := value.(string); isString && !ref.Hex && ref.Key == "" {
+			if id, err := primitive.ObjectIDFromHex(hex); err == nil {
+				data[ref.Field] = id
+				value = id
+			}
+		}
+		checks = append(checks, ref.check(ref.Field, value))
+	}
+	return checks
+}
+
+// check returns the check of one value of the reference.
+func (r reference) check(field string, value interface{}) referenceCheck {
+	return referenceCheck{Field: field, Collection: r.Collection, Key: r.Key, Value: value}
+}
+
+// matchValues returns the values the reference holds when it points at the
+// given documents of r.Collection: their IDs, the IDs as hex strings, or the
+// Key field of the documents.
+func (r reference) matchValues(ctx context.Context, db *mongo.Client, ids []primitive.ObjectID) (bson.A, error) {
+	values := bson.A{}
+	switch {
+	case r.Key != "":
+		cursor, err := config.GetCollection(db, r.Collection).Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return nil, err
+		}
+		var docs []bson.M
+		if err := cursor.All(ctx, &docs); err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			if value, ok := doc[r.Key]; ok && value != nil {
+				values = append(values, value)
+			}
+		}
+	case r.Hex:
+		for _, id := range ids {
+			values = append(values, id.Hex())
+		}
+	default:
+		for _, id := range ids {
+			values = append(values, id)
+		}
+	}
+	return values, nil
+}
+
+// checkReferences verifies that every referenced document exists and is not
+// in the trash. Empty values (nil, "", a zero ObjectID, 0 for Key references)
+// are skipped; whether a reference is required is up to the handler.
+func checkReferences(db *mongo.Client, checks ...referenceCheck) ([]brokenReference, error) {
+	broken := []brokenReference{}
+	for _, check := range checks {
+		if check.Key != "" {
+			// Keys such as unique_id are plain values; zero means unset
+			if check.Value == nil || reflect.ValueOf(check.Value).IsZero() {
+				continue
+			}
+			count, err := config.GetCollection(db, check.Collection).CountDocuments(context.TODO(), notTrashed(bson.M{check.Key: check.Value}))
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				broken = append(broken, brokenReference{Field: check.Field, Value: fmt.Sprint(check.Value), Collection: check.Collection, Reason: "not_found"})
+			}
+			continue
+		}
+
+		var id primitive.ObjectID
+		switch v := check.Value.(type) {
+		case nil:
+			continue
+		case primitive.ObjectID:
+			id = v
+		case *primitive.ObjectID:
+			if v == nil {
+				continue
+			}
+			id = *v
+		case string:
+			if v == "" {
+				continue
+			}
+			parsed, err := primitive.ObjectIDFromHex(v)
+			if err != nil {
+				broken = append(broken, brokenReference{Field: check.Field, Value: v, Collection: check.Collection, Reason: "invalid_id"})
+				continue
+			}
+			id = parsed
+		default:
+			broken = append(broken, brokenReference{Field: check.Field, Value: fmt.Sprint(v), Collection: check.Collection, Reason: "invalid_id"})
+			continue
+		}
+		if id.IsZero() {
+			continue
+		}
+
+		count, err := config.GetCollection(db, check.Collection).CountDocuments(context.TODO(), notTrashed(bson.M{"_id": id}))
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			broken = append(broken, brokenReference{Field: check.Field, Value: id.Hex(), Collection: check.Collection, Reason: "not_found"})
+		}
+	}
+	return broken, nil
+}
+
+// brokenReferencesError is the 422 returned for broken references.
+func brokenReferencesError(broken []brokenReference) error {
+	return utils.ValidationFailed("Some referenced documents do not exist").With("references", broken)
+}
+
+// contractProductChecks returns the product references of contract lines.
+func contractProductChecks(products []models.ContractProduct) []referenceCheck {
+	checks := make([]referenceCheck, 0, len(products))
+	for i, line := range products {
+		checks = append(checks, referenceCheck{
+			Field:      fmt.Sprintf("products[%d].product_id", i),
+			Collection: "products",
+			Value:      line.ProductID,
+		})
+	}
+	return checks
+}
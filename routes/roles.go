@@ -0,0 +1,18 @@
+package routes
+
+import "fiber-ecommerce/models"
+
+// Role sets used to declare which admins may call each protected route.
+var (
+	// allAdminRoles grants access to every signed-in admin.
+	allAdminRoles = []string{models.RoleSuperAdmin, models.RoleManager, models.RoleContentEditor, models.RoleSales}
+
+	// catalogRoles manage the storefront: categories, products, banners and site content.
+	catalogRoles = []string{models.RoleSuperAdmin, models.RoleManager, models.RoleContentEditor}
+
+	// salesRoles manage the CRM side: orders, companies, clients, counterparties and contracts.
+	salesRoles = []string{models.RoleSuperAdmin, models.RoleManager, models.RoleSales}
+
+	// superAdminRoles is reserved for managing admin accounts.
+	superAdminRoles = []string{models.RoleSuperAdmin}
+)
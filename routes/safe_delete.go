@@ -0,0 +1,454 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errHasDependents is returned when a delete is refused because other
+// documents still reference the document.
+var errHasDependents = errors.New("document has dependents")
+
+// deleteModeError reports an invalid ?cascade / ?reassign_to combination.
+type deleteModeError struct {
+	msg string
+}
+
+func (e *deleteModeError) Error() string {
+	return e.msg
+}
+
+// dependent counts the documents of one collection that reference the
+// deleted document through one field.
+type dependent struct {
+	Collection string `json:"collection"`
+	Field      string `json:"field"`
+	Count      int64  `json:"count"`
+	OnDelete   string `json:"on_delete"`
+}
+
+// deleteReport describes what a delete did, or why it was refused.
+type deleteReport struct {
+	Mode       string           `json:"mode"` // restrict, cascade or reassign
+	Dependents []dependent      `json:"dependents"`
+	Trashed    map[string]int64 `json:"trashed,omitempty"`
+}
+
+// reassignment records the documents a reassign moved from one reference
+// value to another.
+type reassignment struct {
+	ids      []primitive.ObjectID
+	from, to interface{}
+}
+
+// ownedReference is a reference together with the collection holding it.
+type ownedReference struct {
+	Owner string
+	reference
+}
+
+// referencesTo returns every reference pointing at the given collection.
+func referencesTo(collection string) []ownedReference {
+	owners := make([]string, 0, len(referenceSchema))
+	for owner := range referenceSchema {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	var refs []ownedReference
+	for _, owner := range owners {
+		for _, ref := range referenceSchema[owner] {
+			if ref.Collection == collection {
+				refs = append(refs, ownedReference{Owner: owner, reference: ref})
+			}
+		}
+	}
+	return refs
+}
+
+// findDependents counts the documents referencing any of the given IDs.
+// Trashed documents do not count; restoring them re-checks their references.
+func findDependents(ctx context.Context, db *mongo.Client, collection string, ids []primitive.ObjectID) ([]dependent, error) {
+	dependents := []dependent{}
+	for _, ref := range referencesTo(collection) {
+		values, err := ref.matchValues(ctx, db, ids)
+		if err != nil {
+			return nil, err
+		}
+		count, err := config.GetCollection(db, ref.Owner).CountDocuments(ctx, notTrashed(bson.M{ref.Field: bson.M{"$in": values}}))
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			dependents = append(dependents, dependent{Collection: ref.Owner, Field: ref.Field, Count: count, OnDelete: ref.OnDelete})
+		}
+	}
+	return dependents, nil
+}
+
+// runInTransaction runs fn in a multi-document transaction. Transactions need
+// a replica set; on a standalone server fn runs without one, which is why the
+// delete modes check everything that can refuse the operation before they
+// write anything.
+func runInTransaction(db *mongo.Client, fn func(ctx context.Context) error) error {
+	session, err := db.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.TODO())
+
+	_, err = session.WithTransaction(context.TODO(), func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(20) { // IllegalOperation: not a replica set
+		return fn(context.TODO())
+	}
+	return err
+}
+
+// cascadePlan collects the documents a cascading delete trashes, per collection.
+type cascadePlan struct {
+	root primitive.ObjectID
+	ids  map[string][]primitive.ObjectID
+	seen map[primitive.ObjectID]bool
+}
+
+// add records the documents to trash and follows their own dependents.
+func (p *cascadePlan) add(ctx context.Context, db *mongo.Client, collection string, ids []primitive.ObjectID) error {
+	var fresh []primitive.ObjectID
+	for _, id := range ids {
+		if !p.seen[id] {
+			p.seen[id] = true
+			fresh = append(fresh, id)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	p.ids[collection] = append(p.ids[collection], fresh...)
+
+	for _, ref := range referencesTo(collection) {
+		values, err := ref.matchValues(ctx, db, fresh)
+		if err != nil {
+			return err
+		}
+		filter := notTrashed(bson.M{ref.Field: bson.M{"$in": values}})
+		switch ref.OnDelete {
+		case onDeleteRestrict:
+			count, err := config.GetCollection(db, ref.Owner).CountDocuments(ctx, filter)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return errHasDependents
+			}
+		case onDeleteCascade:
+			children, err := findIDs(ctx, db, ref.Owner, filter)
+			if err != nil {
+				return err
+			}
+			if err := p.add(ctx, db, ref.Owner, children); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply unsets the optional references to the planned documents and trashes
+// them. Everything but the root is marked as trashed with the root.
+func (p *cascadePlan) apply(ctx context.Context, db *mongo.Client, deletedBy string) (map[string]int64, error) {
+	trashedCount := map[string]int64{}
+	for collection, ids := range p.ids {
+		for _, ref := range referencesTo(collection) {
+			if ref.OnDelete != onDeleteUnset {
+				continue
+			}
+			values, err := ref.matchValues(ctx, db, ids)
+			if err != nil {
+				return nil, err
+			}
+			_, err = config.GetCollection(db, ref.Owner).UpdateMany(ctx,
+				bson.M{ref.Field: bson.M{"$in": values}},
+				bson.M{"$unset": bson.M{ref.Field: ""}},
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for collection, ids := range p.ids {
+		var children []primitive.ObjectID
+		for _, id := range ids {
+			if id != p.root {
+				children = append(children, id)
+			}
+		}
+		if len(children) < len(ids) {
+			if _, err := trashDocuments(ctx, db, collection, []primitive.ObjectID{p.root}, deletedBy, nil); err != nil {
+				return nil, err
+			}
+		}
+		if len(children) > 0 {
+			if _, err := trashDocuments(ctx, db, collection, children, deletedBy, &p.root); err != nil {
+				return nil, err
+			}
+		}
+		trashedCount[collection] = int64(len(ids))
+	}
+	return trashedCount, nil
+}
+
+// safeDelete moves the document addressed by a DELETE request to the trash.
+// By default it refuses to delete documents other documents reference;
+// ?cascade=true trashes or detaches the dependents and ?reassign_to=<id>
+// points them at another document of the same collection first.
+func safeDelete(c *fiber.Ctx, db *mongo.Client, collection string, id primitive.ObjectID) (deleteReport, error) {
+	report := deleteReport{Mode: "restrict", Dependents: []dependent{}}
+	coll := config.GetCollection(db, collection)
+	deletedBy, _ := c.Locals("admin_id").(string)
+
+	cascade := c.QueryBool("cascade")
+	var target primitive.ObjectID
+	if reassignTo := c.Query("reassign_to"); reassignTo != "" {
+		if cascade {
+			return report, &deleteModeError{"cascade and reassign_to cannot be combined"}
+		}
+		parsed, err := primitive.ObjectIDFromHex(reassignTo)
+		if err != nil {
+			return report, &deleteModeError{"Invalid reassign_to"}
+		}
+		if parsed == id {
+			return report, &deleteModeError{"reassign_to must be a different document"}
+		}
+		if count, err := coll.CountDocuments(context.TODO(), notTrashed(bson.M{"_id": parsed})); err != nil {
+			return report, err
+		} else if count == 0 {
+			return report, &deleteModeError{"reassign_to does not exist"}
+		}
+		target = parsed
+		report.Mode = "reassign"
+	} else if cascade {
+		report.Mode = "cascade"
+	}
+
+	if count, err := coll.CountDocuments(context.TODO(), notTrashed(bson.M{"_id": id})); err != nil {
+		return report, err
+	} else if count == 0 {
+		return report, mongo.ErrNoDocuments
+	}
+
+	dependents, err := findDependents(context.TODO(), db, collection, []primitive.ObjectID{id})
+	if err != nil {
+		return report, err
+	}
+	report.Dependents = dependents
+
+	switch report.Mode {
+	case "reassign":
+		for _, dep := range dependents {
+			if dep.OnDelete == onDeleteRestrict {
+				return report, errHasDependents
+			}
+		}
+		var reassigned map[ownedReference]reassignment
+		err = runInTransaction(db, func(ctx context.Context) error {
+			reassigned = map[ownedReference]reassignment{}
+			for _, ref := range referencesTo(collection) {
+				from, err := ref.matchValues(ctx, db, []primitive.ObjectID{id})
+				if err != nil {
+					return err
+				}
+				if len(from) == 0 {
+					continue
+				}
+				filter := notTrashed(bson.M{ref.Field: from[0]})
+				ids, err := findIDs(ctx, db, ref.Owner, filter)
+				if err != nil {
+					return err
+				}
+				if len(ids) == 0 {
+					continue
+				}
+				to, err := ref.matchValues(ctx, db, []primitive.ObjectID{target})
+				if err != nil {
+					return err
+				}
+				if len(to) == 0 {
+					return &deleteModeError{"reassign_to has no " + ref.Key}
+				}
+				if _, err := config.GetCollection(db, ref.Owner).UpdateMany(ctx, filter, bson.M{"$set": bson.M{ref.Field: to[0]}}); err != nil {
+					return err
+				}
+				reassigned[ref] = reassignment{ids: ids, from: from[0], to: to[0]}
+			}
+			return trashOne(ctx, db, collection, id, deletedBy)
+		})
+		if err == nil {
+			for ref, moved := range reassigned {
+				for _, depID := range moved.ids {
+					recordAudit(c, db, models.AuditActionUpdate, ref.Owner, depID, bson.M{ref.Field: moved.from}, bson.M{ref.Field: moved.to})
+				}
+			}
+			recordTrashAudit(c, db, collection, id, nil)
+			if kind := partyKind(collection); kind != "" {
+				syncParty(db, kind, target)
+			}
+			// Contract history shows funnel names
+			if collection == "funnels" {
+				contracts, _ := findContracts(context.TODO(), db, bson.M{"funnel_id": target})
+				syncContractParties(db, contracts...)
+			}
+		}
+		return report, err
+
+	case "cascade":
+		var contracts []models.Contract
+		var plan *cascadePlan
+		err = runInTransaction(db, func(ctx context.Context) error {
+			plan = &cascadePlan{root: id, ids: map[string][]primitive.ObjectID{}, seen: map[primitive.ObjectID]bool{}}
+			if err := plan.add(ctx, db, collection, []primitive.ObjectID{id}); err != nil {
+				return err
+			}
+
+			// Remember the contracts removed or losing their funnel so the
+			// history of their parties can be rebuilt
+			affected := bson.A{}
+			if ids := plan.ids["contracts"]; len(ids) > 0 {
+				affected = append(affected, bson.M{"_id": bson.M{"$in": ids}})
+			}
+			if ids := plan.ids["funnels"]; len(ids) > 0 {
+				affected = append(affected, bson.M{"funnel_id": bson.M{"$in": ids}})
+			}
+			contracts = nil
+			if len(affected) > 0 {
+				var err error
+				if contracts, err = findContracts(ctx, db, bson.M{"$or": affected}); err != nil {
+					return err
+				}
+			}
+
+			trashedCount, err := plan.apply(ctx, db, deletedBy)
+			report.Trashed = trashedCount
+			return err
+		})
+		if err == nil {
+			for planned, ids := range plan.ids {
+				for _, plannedID := range ids {
+					if plannedID == id {
+						recordTrashAudit(c, db, planned, plannedID, nil)
+					} else {
+						recordTrashAudit(c, db, planned, plannedID, &id)
+					}
+				}
+			}
+			syncContractParties(db, contracts...)
+		}
+		return report, err
+
+	default:
+		if len(dependents) > 0 {
+			return report, errHasDependents
+		}
+		if err := trashOne(context.TODO(), db, collection, id, deletedBy); err != nil {
+			return report, err
+		}
+		recordTrashAudit(c, db, collection, id, nil)
+		return report, nil
+	}
+}
+
+// trashOne trashes a single document, reporting mongo.ErrNoDocuments when it is gone.
+func trashOne(ctx context.Context, db *mongo.Client, collection string, id primitive.ObjectID, deletedBy string) error {
+	count, err := trashDocuments(ctx, db, collection, []primitive.ObjectID{id}, deletedBy, nil)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// findIDs returns the IDs of the documents matching a filter.
+func findIDs(ctx context.Context, db *mongo.Client, collection string, filter bson.M) ([]primitive.ObjectID, error) {
+	cursor, err := config.GetCollection(db, collection).Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return ids, nil
+}
+
+// findContracts loads the contracts matching a filter.
+func findContracts(ctx context.Context, db *mongo.Client, filter bson.M) ([]models.Contract, error) {
+	var contracts []models.Contract
+	cursor, err := config.GetCollection(db, "contracts").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &contracts)
+	return contracts, err
+}
+
+// partyKind returns the history party kind stored in a collection, if any.
+func partyKind(collection string) string {
+	for kind, party := range historyParties {
+		if party.Collection == collection {
+			return kind
+		}
+	}
+	return ""
+}
+
+// deleteError maps an error from safeDelete to its API error.
+func deleteError(err error, report deleteReport, notFound, failed string) error {
+	var modeErr *deleteModeError
+	switch {
+	case errors.As(err, &modeErr):
+		return utils.BadRequest(modeErr.Error())
+	case errors.Is(err, errHasDependents):
+		return utils.Conflict("Other documents still reference this document; delete with ?cascade=true or ?reassign_to=<id>").
+			With("dependents", report.Dependents)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return utils.NotFound(notFound)
+	default:
+		return utils.Internal(failed)
+	}
+}
+
+// deleteResult builds the success response of a delete, describing the
+// dependents that were trashed, detached or reassigned.
+func deleteResult(message string, report deleteReport) fiber.Map {
+	result := fiber.Map{"message": message}
+	if report.Mode != "restrict" {
+		result["mode"] = report.Mode
+		result["dependents"] = report.Dependents
+		if report.Trashed != nil {
+			result["trashed"] = report.Trashed
+		}
+	}
+	return result
+}
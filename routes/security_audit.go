@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+	"fiber-ecommerce/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// recordLoginAttempt stores a login attempt in the security audit log. Failures
+// to write the log are reported but never block the login flow.
+func recordLoginAttempt(db *mongo.Client, c *fiber.Ctx, subjectType, identifier string, subjectID *primitive.ObjectID, success bool, reason string) {
+	attempt := models.LoginAttempt{
+		SubjectType: subjectType,
+		Identifier:  identifier,
+		SubjectID:   subjectID,
+		IP:          c.IP(),
+		UserAgent:   c.Get("User-Agent"),
+		Success:     success,
+		Reason:      reason,
+		CreatedAt:   time.Now(),
+	}
+
+	collection := config.GetCollection(db, "login_attempts")
+	if _, err := collection.InsertOne(context.TODO(), attempt); err != nil {
+		log.Printf("Failed to record %s login attempt: %v", subjectType, err)
+	}
+}
+
+// forgetLoginHistory deletes the login attempts and lockout counters kept for
+// the given identifiers (phone numbers, emails) of a subject.
+func forgetLoginHistory(ctx context.Context, db *mongo.Client, subjectType string, subjectID primitive.ObjectID, identifiers ...string) error {
+	known := []string{}
+	keys := []string{}
+	for _, identifier := range identifiers {
+		if identifier != "" {
+			known = append(known, identifier)
+			keys = append(keys, accountThrottle(subjectType, identifier).Key)
+		}
+	}
+
+	_, err := config.GetCollection(db, "login_attempts").DeleteMany(ctx, bson.M{
+		"subject_type": subjectType,
+		"$or": bson.A{
+			bson.M{"subject_id": subjectID},
+			bson.M{"identifier": bson.M{"$in": known}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = config.GetCollection(db, "login_throttles").DeleteMany(ctx, bson.M{"key": bson.M{"$in": keys}})
+	return err
+}
+
+// SecurityAuditRoutes exposes the login audit log and system diagnostics to superadmins.
+func SecurityAuditRoutes(app fiber.Router, db *mongo.Client) {
+	security := app.Group("/admin/security", middleware.AdminJWTMiddleware(superAdminRoles...))
+
+	ensureLoginThrottleIndexes(db)
+
+	// List login attempts with optional filters
+	security.Get("/login-attempts", func(c *fiber.Ctx) error {
+		page, limit, skip := utils.ParsePaginationParams(c)
+
+		filter := bson.M{}
+		if subjectType := c.Query("subject_type"); subjectType != "" {
+			filter["subject_type"] = subjectType
+		}
+		if identifier := c.Query("identifier"); identifier != "" {
+			filter["identifier"] = identifier
+		}
+		if ip := c.Query("ip"); ip != "" {
+			filter["ip"] = ip
+		}
+		if reason := c.Query("reason"); reason != "" {
+			filter["reason"] = reason
+		}
+		switch c.Query("success") {
+		case "true":
+			filter["success"] = true
+		case "false":
+			filter["success"] = false
+		}
+
+		startDate, endDate, err := utils.ParseDateRange(c.Query("start_date"), c.Query("end_date"))
+		if err != nil {
+			return utils.BadRequest(err.Error())
+		}
+		if !startDate.IsZero() || !endDate.IsZero() {
+			createdAt := bson.M{}
+			if !startDate.IsZero() {
+				createdAt["$gte"] = startDate
+			}
+			if !endDate.IsZero() {
+				createdAt["$lt"] = endDate
+			}
+			filter["created_at"] = createdAt
+		}
+
+		collection := config.GetCollection(db, "login_attempts")
+		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+		cursor, err := collection.Find(context.TODO(), filter, opts)
+		if err != nil {
+			return utils.Internal("Failed to fetch login attempts")
+		}
+		defer cursor.Close(context.TODO())
+
+		attempts := []models.LoginAttempt{}
+		if err = cursor.All(context.TODO(), &attempts); err != nil {
+			return utils.Internal("Failed to decode login attempts")
+		}
+
+		total, _ := collection.CountDocuments(context.TODO(), filter)
+
+		return c.JSON(utils.PaginationResponse(attempts, total, page, limit))
+	})
+
+	// List accounts and IPs that are currently locked out
+	security.Get("/lockouts", func(c *fiber.Ctx) error {
+		filter := bson.M{"locked_until": bson.M{"$gt": time.Now()}}
+		if c.Query("all") == "true" {
+			// Include counters that have failures but are not locked yet
+			filter = bson.M{"expires_at": bson.M{"$gt": time.Now()}}
+		}
+
+		collection := config.GetCollection(db, "login_throttles")
+		cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "last_failure_at", Value: -1}}))
+		if err != nil {
+			return utils.Internal("Failed to fetch lockouts")
+		}
+		defer cursor.Close(context.TODO())
+
+		throttles := []models.LoginThrottle{}
+		if err = cursor.All(context.TODO(), &throttles); err != nil {
+			return utils.Internal("Failed to decode lockouts")
+		}
+
+		return c.JSON(fiber.Map{
+			"data":  throttles,
+			"total": len(throttles),
+		})
+	})
+
+	// Unlock an account (subject_type + identifier) and/or an IP address
+	security.Post("/unlock", func(c *fiber.Ctx) error {
+		var req struct {
+			SubjectType string `json:"subject_type" validate:"enum=admin|user"`
+			Identifier  string `json:"identifier"`
+			IP          string `json:"ip"`
+		}
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		var targets []loginThrottleTarget
+		if req.Identifier != "" {
+			if req.SubjectType == "" {
+				return validation.Failed(validation.Error("subject_type", "required", "Field is required"))
+			}
+			targets = append(targets, accountThrottle(req.SubjectType, req.Identifier))
+		}
+		if req.IP != "" {
+			targets = append(targets, ipThrottle(req.IP))
+		}
+		if len(targets) == 0 {
+			return validation.Failed(validation.Error("identifier", "required", "identifier or ip is required"))
+		}
+
+		cleared, err := clearLoginFailures(db, targets...)
+		if err != nil {
+			return utils.Internal("Failed to unlock")
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Unlocked successfully",
+			"cleared": cleared,
+		})
+	})
+}
@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var errInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// newRefreshToken returns a random opaque token suitable for use as a refresh token.
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashToken returns the SHA-256 hex digest stored in place of a plaintext token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createSession stores a new session for the subject and returns it along with
+// the plaintext refresh token, which is never persisted.
+func createSession(db *mongo.Client, c *fiber.Ctx, subjectType string, subjectID primitive.ObjectID) (models.Session, string, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return models.Session{}, "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		SubjectID:        subjectID,
+		SubjectType:      subjectType,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        c.Get("User-Agent"),
+		IP:               c.IP(),
+		ExpiresAt:        now.Add(refreshTokenTTL),
+		LastUsedAt:       now,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	collection := config.GetCollection(db, "sessions")
+	result, err := collection.InsertOne(context.TODO(), session)
+	if err != nil {
+		return models.Session{}, "", err
+	}
+
+	session.ID = result.InsertedID.(primitive.ObjectID)
+	return session, refreshToken, nil
+}
+
+// rotateSession exchanges a valid refresh token for a new one on the same
+// session. The old refresh token stops working immediately.
+func rotateSession(db *mongo.Client, refreshToken, subjectType string) (models.Session, string, error) {
+	if refreshToken == "" {
+		return models.Session{}, "", errInvalidRefreshToken
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return models.Session{}, "", err
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"refresh_token_hash": hashToken(refreshToken),
+		"subject_type":       subjectType,
+		"revoked_at":         nil,
+		"expires_at":         bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{
+		"refresh_token_hash": hashToken(newToken),
+		"last_used_at":       now,
+		"updated_at":         now,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	collection := config.GetCollection(db, "sessions")
+	var session models.Session
+	if err := collection.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(&session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Session{}, "", errInvalidRefreshToken
+		}
+		return models.Session{}, "", err
+	}
+
+	return session, newToken, nil
+}
+
+// revokeSession marks a single session as revoked.
+func revokeSession(db *mongo.Client, sessionID string) error {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	collection := config.GetCollection(db, "sessions")
+	_, err = collection.UpdateOne(context.TODO(),
+		bson.M{"_id": id, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": now, "updated_at": now}},
+	)
+	return err
+}
+
+// revokeAllSessions revokes every active session of the subject and returns
+// how many were revoked.
+func revokeAllSessions(db *mongo.Client, subjectType string, subjectID primitive.ObjectID) (int64, error) {
+	now := time.Now()
+	collection := config.GetCollection(db, "sessions")
+	result, err := collection.UpdateMany(context.TODO(),
+		bson.M{"subject_id": subjectID, "subject_type": subjectType, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": now, "updated_at": now}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// revokeOtherSessions revokes every active session of the subject except keepSessionID.
+func revokeOtherSessions(db *mongo.Client, subjectType string, subjectID primitive.ObjectID, keepSessionID string) error {
+	filter := bson.M{"subject_id": subjectID, "subject_type": subjectType, "revoked_at": nil}
+	if keepID, err := primitive.ObjectIDFromHex(keepSessionID); err == nil {
+		filter["_id"] = bson.M{"$ne": keepID}
+	}
+
+	now := time.Now()
+	collection := config.GetCollection(db, "sessions")
+	_, err := collection.UpdateMany(context.TODO(), filter, bson.M{"$set": bson.M{"revoked_at": now, "updated_at": now}})
+	return err
+}
@@ -0,0 +1,364 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trashResource is a resource whose deletes move documents to the trash.
+type trashResource struct {
+	Collection string
+	Roles      []string
+}
+
+// trashResources maps the API resource name to its collection. Route
+// functions register their resource with registerTrash.
+var trashResources = map[string]trashResource{}
+
+// registerTrash makes a resource available under /trash/:resource.
+func registerTrash(resource, collection string, roles []string) {
+	trashResources[resource] = trashResource{Collection: collection, Roles: roles}
+}
+
+// notTrashed restricts a filter to documents that are not in the trash.
+func notTrashed(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// trashed matches documents in the trash.
+func trashed(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$ne": nil}
+	return filter
+}
+
+// trashDocuments moves documents to the trash. Documents trashed by a
+// cascade carry deleted_with, the ID of the document whose delete trashed
+// them, so restoring that document restores them too.
+func trashDocuments(ctx context.Context, db *mongo.Client, collection string, ids []primitive.ObjectID, deletedBy string, deletedWith *primitive.ObjectID) (int64, error) {
+	set := bson.M{"deleted_at": time.Now(), "deleted_by": deletedBy}
+	if deletedWith != nil {
+		set["deleted_with"] = *deletedWith
+	}
+	result, err := config.GetCollection(db, collection).UpdateMany(ctx,
+		notTrashed(bson.M{"_id": bson.M{"$in": ids}}),
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// trashRetention is how long trashed documents are kept before the purge
+// job deletes them for good (TRASH_RETENTION_DAYS, default 30).
+func trashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// purgeTrash permanently deletes documents trashed before the cutoff.
+func purgeTrash(db *mongo.Client, cutoff time.Time) {
+	for resource, res := range trashResources {
+		ids, err := findIDs(context.TODO(), db, res.Collection, bson.M{"deleted_at": bson.M{"$lt": cutoff}})
+		if err != nil || len(ids) == 0 {
+			if err != nil {
+				log.Printf("Failed to purge trashed %s: %v", resource, err)
+			}
+			continue
+		}
+		result, err := config.GetCollection(db, res.Collection).DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			log.Printf("Failed to purge trashed %s: %v", resource, err)
+			continue
+		}
+		for _, id := range ids {
+			insertAudit(db, models.AuditEvent{
+				Action:     models.AuditActionPurge,
+				Collection: res.Collection,
+				DocumentID: id,
+				ActorType:  "system",
+				Changes:    map[string]models.AuditChange{},
+			})
+		}
+		log.Printf("Purged %d trashed %s", result.DeletedCount, resource)
+	}
+}
+
+// StartTrashPurger runs the purge job once an hour in the background.
+func StartTrashPurger(db *mongo.Client) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			purgeTrash(db, time.Now().Add(-trashRetention()))
+			<-ticker.C
+		}
+	}()
+}
+
+// hasAdminRole reports whether the admin behind the request has one of roles.
+func hasAdminRole(c *fiber.Ctx, roles []string) bool {
+	role, _ := c.Locals("admin_role").(string)
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// TrashRoutes lists, restores and purges trashed documents of every resource.
+func TrashRoutes(app fiber.Router, db *mongo.Client) {
+	trash := app.Group("/trash", middleware.AdminJWTMiddleware(allAdminRoles...))
+
+	// resolve looks up the resource and checks the admin may manage it.
+	resolve := func(c *fiber.Ctx) (trashResource, bool, error) {
+		res, ok := trashResources[c.Params("resource")]
+		if !ok {
+			return res, false, utils.NotFound("Unknown resource")
+		}
+		if !hasAdminRole(c, res.Roles) {
+			return res, false, utils.Forbidden("Insufficient permissions")
+		}
+		return res, true, nil
+	}
+
+	// List trashed documents of a resource, most recently deleted first
+	trash.Get("/:resource", func(c *fiber.Ctx) error {
+		res, ok, err := resolve(c)
+		if !ok {
+			return err
+		}
+
+		page, limit, skip := utils.ParsePaginationParams(c)
+		filter := trashed(bson.M{})
+		collection := config.GetCollection(db, res.Collection)
+		opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+
+		cursor, err := collection.Find(context.TODO(), filter, opts)
+		if err != nil {
+			return utils.Internal("Failed to fetch trash")
+		}
+		defer cursor.Close(context.TODO())
+
+		docs := []bson.M{}
+		if err = cursor.All(context.TODO(), &docs); err != nil {
+			return utils.Internal("Failed to decode trash")
+		}
+
+		total, _ := collection.CountDocuments(context.TODO(), filter)
+
+		response := utils.PaginationResponse(docs, total, page, limit)
+		response["retention_days"] = int(trashRetention().Hours() / 24)
+		return c.JSON(response)
+	})
+
+	// Restore a trashed document together with the documents its delete cascaded to
+	trash.Post("/:resource/:id/restore", func(c *fiber.Ctx) error {
+		res, ok, err := resolve(c)
+		if !ok {
+			return err
+		}
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.BadRequest("Invalid ID")
+		}
+
+		var doc bson.M
+		if err := config.GetCollection(db, res.Collection).FindOne(context.TODO(), trashed(bson.M{"_id": id})).Decode(&doc); err != nil {
+			return utils.NotFound("Document not found in trash")
+		}
+
+		// The documents may point at something trashed or purged in the
+		// meantime, such as a restored order at a product that is gone
+		batch := trashed(bson.M{"$or": bson.A{bson.M{"_id": id}, bson.M{"deleted_with": id}}})
+		checks, err := restoreChecks(context.TODO(), db, res.Collection, batch)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		broken, err := checkReferences(db, checks...)
+		if err != nil {
+			return utils.Internal("Failed to verify references")
+		}
+		if len(broken) > 0 {
+			return utils.Conflict("The document references documents that no longer exist").With("references", broken)
+		}
+
+		contracts, _ := findContracts(context.TODO(), db, batch)
+		restoredIDs := map[string][]primitive.ObjectID{}
+		var orders []models.Order
+		if cursor, err := config.GetCollection(db, "orders").Find(context.TODO(), batch); err == nil {
+			cursor.All(context.TODO(), &orders)
+		}
+
+		restored := fiber.Map{}
+		for _, collection := range append([]string{res.Collection}, cascadeTargets(res.Collection)...) {
+			ids, err := findIDs(context.TODO(), db, collection, batch)
+			if err != nil {
+				return utils.Internal("Failed to restore document")
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			restoredIDs[collection] = ids
+			result, err := config.GetCollection(db, collection).UpdateMany(context.TODO(), bson.M{"_id": bson.M{"$in": ids}}, bson.M{
+				"$unset": bson.M{"deleted_at": "", "deleted_by": "", "deleted_with": ""},
+			})
+			if err != nil {
+				return utils.Internal("Failed to restore document")
+			}
+			if result.ModifiedCount > 0 {
+				restored[collection] = result.ModifiedCount
+			}
+		}
+
+		for collection, ids := range restoredIDs {
+			for _, restoredID := range ids {
+				recordAudit(c, db, models.AuditActionRestore, collection, restoredID, bson.M{"deleted_by": doc["deleted_by"]}, nil)
+			}
+		}
+		syncContractParties(db, contracts...)
+		syncOrderClients(db, orders...)
+
+		return c.JSON(fiber.Map{"message": "Document restored", "restored": restored})
+	})
+
+	// Permanently delete a trashed document before its retention expires
+	trash.Delete("/:resource/:id", func(c *fiber.Ctx) error {
+		res, ok, err := resolve(c)
+		if !ok {
+			return err
+		}
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.BadRequest("Invalid ID")
+		}
+
+		var purged bson.M
+		err = config.GetCollection(db, res.Collection).FindOneAndDelete(context.TODO(), trashed(bson.M{"_id": id})).Decode(&purged)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("Document not found in trash")
+		}
+		if err != nil {
+			return utils.Internal("Failed to purge document")
+		}
+		recordAudit(c, db, models.AuditActionPurge, res.Collection, id, purged, nil)
+
+		return c.JSON(fiber.Map{"message": "Document permanently deleted"})
+	})
+}
+
+// restoreChecks collects the references held by the documents a restore brings
+// back. References between those documents are skipped, as they come back
+// together.
+func restoreChecks(ctx context.Context, db *mongo.Client, collection string, batch bson.M) ([]referenceCheck, error) {
+	restoring := map[string][]bson.M{}
+	for _, current := range append([]string{collection}, cascadeTargets(collection)...) {
+		cursor, err := config.GetCollection(db, current).Find(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		var found []bson.M
+		if err := cursor.All(ctx, &found); err != nil {
+			return nil, err
+		}
+		restoring[current] = found
+	}
+
+	var checks []referenceCheck
+	for owner, docs := range restoring {
+		for _, doc := range docs {
+			for _, ref := range referenceSchema[owner] {
+				for _, value := range referenceValues(doc, ref.Field) {
+					if !comesBack(restoring[ref.Collection], ref, value) {
+						checks = append(checks, ref.check(ref.Field, value))
+					}
+				}
+			}
+		}
+	}
+	return checks, nil
+}
+
+// comesBack reports whether a reference value points at one of the documents
+// being restored.
+func comesBack(docs []bson.M, ref reference, value interface{}) bool {
+	key := ref.Key
+	if key == "" {
+		key = "_id"
+	}
+	for _, doc := range docs {
+		target := doc[key]
+		if id, ok := target.(primitive.ObjectID); ok && ref.Hex {
+			target = id.Hex()
+		}
+		if target != nil && fmt.Sprint(target) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// referenceValues returns the values at a dotted path, descending into arrays
+// the way MongoDB queries do (products.product_id reads every order line).
+func referenceValues(value interface{}, path string) []interface{} {
+	if path == "" {
+		return []interface{}{value}
+	}
+	switch v := value.(type) {
+	case bson.M:
+		key, rest, _ := strings.Cut(path, ".")
+		field, ok := v[key]
+		if !ok {
+			return nil
+		}
+		return referenceValues(field, rest)
+	case bson.A:
+		var values []interface{}
+		for _, item := range v {
+			values = append(values, referenceValues(item, path)...)
+		}
+		return values
+	}
+	return nil
+}
+
+// cascadeTargets returns every collection a cascading delete from the given
+// collection can reach.
+func cascadeTargets(collection string) []string {
+	var targets []string
+	seen := map[string]bool{collection: true}
+	queue := []string{collection}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, ref := range referencesTo(current) {
+			if ref.OnDelete == onDeleteCascade && !seen[ref.Owner] {
+				seen[ref.Owner] = true
+				targets = append(targets, ref.Owner)
+				queue = append(queue, ref.Owner)
+			}
+		}
+	}
+	return targets
+}
@@ -3,12 +3,18 @@ package routes
 
 import (
 	"context"
+	"encoding/json"
+	"os"
 	"time"
 
 	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
 	"fiber-ecommerce/models"
+	"fiber-ecommerce/utils"
+	"fiber-ecommerce/validation"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -21,18 +27,12 @@ func UserAuthRoutes(app fiber.Router, db *mongo.Client) {
 	// User Registration
 	auth.Post("/register", func(c *fiber.Ctx) error {
 		var req models.UserRegisterRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		// Validate required fields
-		if req.Name == "" || req.Phone == "" || req.Password == "" {
-			return c.Status(400).JSON(fiber.Map{"error": "Name, phone, and password are required"})
-		}
-
-		// Validate phone format (Uzbekistan format: +998XXXXXXXXX)
-		if len(req.Phone) != 13 || req.Phone[:4] != "+998" {
-			return c.Status(400).JSON(fiber.Map{"error": "Phone must be in format +998XXXXXXXXX"})
+		if ok, msg := utils.IsStrongPassword(req.Password); !ok {
+			return validation.Failed(validation.Error("password", "weak_password", msg))
 		}
 
 		collection := config.GetCollection(db, "users")
@@ -41,21 +41,21 @@ func UserAuthRoutes(app fiber.Router, db *mongo.Client) {
 		var existingUser models.User
 		err := collection.FindOne(context.TODO(), bson.M{"phone": req.Phone}).Decode(&existingUser)
 		if err == nil {
-			return c.Status(409).JSON(fiber.Map{"error": "User with this phone number already exists"})
+			return utils.Conflict("User with this phone number already exists")
 		}
 
 		// Check if email already exists (if provided)
 		if req.Email != "" {
 			err = collection.FindOne(context.TODO(), bson.M{"email": req.Email}).Decode(&existingUser)
 			if err == nil {
-				return c.Status(409).JSON(fiber.Map{"error": "User with this email already exists"})
+				return utils.Conflict("User with this email already exists")
 			}
 		}
 
 		// Hash password
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to hash password"})
+			return utils.Internal("Failed to hash password")
 		}
 
 		// Create user
@@ -71,35 +71,42 @@ func UserAuthRoutes(app fiber.Router, db *mongo.Client) {
 
 		result, err := collection.InsertOne(context.TODO(), user)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create user"})
+			return utils.Internal("Failed to create user")
 		}
 
 		user.ID = result.InsertedID.(primitive.ObjectID)
 		user.Password = "" // Don't return password
 
+		session, refreshToken, err := createSession(db, c, "user", user.ID)
+		if err != nil {
+			return utils.Internal("Failed to create session")
+		}
+
 		// Generate JWT token
-		// token, err := generateUserJWT(user.ID.Hex(), user.Phone)
-		// if err != nil {
-		// 	return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
-		// }
+		token, err := generateUserJWT(user.ID.Hex(), user.Phone, session.ID.Hex())
+		if err != nil {
+			return utils.Internal("Failed to generate token")
+		}
 
-		return c.Status(201).JSON(user)
+		return c.Status(201).JSON(models.UserAuthResponse{
+			Token:        token,
+			RefreshToken: refreshToken,
+			User:         user,
+		})
 	})
 
 	// User Login (Phone + Password)
 	auth.Post("/login", func(c *fiber.Ctx) error {
 		var req models.UserLoginRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-		}
-
-		if req.Phone == "" || req.Password == "" {
-			return c.Status(400).JSON(fiber.Map{"error": "Phone and password are required"})
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
 		}
 
-		// Validate phone format
-		if len(req.Phone) != 13 || req.Phone[:4] != "+998" {
-			return c.Status(400).JSON(fiber.Map{"error": "Phone must be in format +998XXXXXXXXX"})
+		// Refuse early while the account or the client IP is locked out
+		accountKey, ipKey := accountThrottle("user", req.Phone), ipThrottle(c.IP())
+		if lockout := loginLockedFor(db, accountKey, ipKey); lockout > 0 {
+			recordLoginAttempt(db, c, "user", req.Phone, nil, false, models.LoginReasonLockedOut)
+			return tooManyLoginAttempts(c, lockout)
 		}
 
 		collection := config.GetCollection(db, "users")
@@ -108,20 +115,28 @@ func UserAuthRoutes(app fiber.Router, db *mongo.Client) {
 		var user models.User
 		err := collection.FindOne(context.TODO(), bson.M{"phone": req.Phone}).Decode(&user)
 		if err != nil {
-			return c.Status(401).JSON(fiber.Map{"error": "Invalid credentials"})
+			registerLoginFailure(db, accountKey, ipKey)
+			recordLoginAttempt(db, c, "user", req.Phone, nil, false, models.LoginReasonUnknownAccount)
+			return utils.Unauthorized("Invalid credentials")
 		}
 
 		// Check if user is active
 		if !user.IsActive {
-			return c.Status(401).JSON(fiber.Map{"error": "Account is deactivated. Please contact administrator."})
+			recordLoginAttempt(db, c, "user", req.Phone, &user.ID, false, models.LoginReasonAccountDeactivated)
+			return utils.Unauthorized("Account is deactivated. Please contact administrator.")
 		}
 
 		// Check password
 		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 		if err != nil {
-			return c.Status(401).JSON(fiber.Map{"error": "Invalid credentials"})
+			registerLoginFailure(db, accountKey, ipKey)
+			recordLoginAttempt(db, c, "user", req.Phone, &user.ID, false, models.LoginReasonInvalidPassword)
+			return utils.Unauthorized("Invalid credentials")
 		}
 
+		clearLoginFailures(db, accountKey)
+		recordLoginAttempt(db, c, "user", req.Phone, &user.ID, true, models.LoginReasonSuccess)
+
 		// Update last login
 		now := time.Now()
 		collection.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{
@@ -134,29 +149,74 @@ func UserAuthRoutes(app fiber.Router, db *mongo.Client) {
 
 		user.Password = "" // Don't return password
 
+		session, refreshToken, err := createSession(db, c, "user", user.ID)
+		if err != nil {
+			return utils.Internal("Failed to create session")
+		}
+
 		// Generate JWT token
-		// token, err := generateUserJWT(user.ID.Hex(), user.Phone)
-		// if err != nil {
-		// 	return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
-		// }
+		token, err := generateUserJWT(user.ID.Hex(), user.Phone, session.ID.Hex())
+		if err != nil {
+			return utils.Internal("Failed to generate token")
+		}
 
-		return c.JSON(user)
+		return c.JSON(models.UserAuthResponse{
+			Token:        token,
+			RefreshToken: refreshToken,
+			User:         user,
+		})
+	})
+
+	// Refresh - exchange a refresh token for a new token pair
+	auth.Post("/refresh", func(c *fiber.Ctx) error {
+		var req models.RefreshTokenRequest
+		if errs := validation.Parse(c.Body(), &req); len(errs) > 0 {
+			return validation.Failed(errs)
+		}
+
+		session, refreshToken, err := rotateSession(db, req.RefreshToken, "user")
+		if err != nil {
+			if err == errInvalidRefreshToken {
+				return utils.Unauthorized("Invalid or expired refresh token")
+			}
+			return utils.Internal("Failed to refresh session")
+		}
+
+		collection := config.GetCollection(db, "users")
+		var user models.User
+		if err := collection.FindOne(context.TODO(), bson.M{"_id": session.SubjectID}).Decode(&user); err != nil || !user.IsActive {
+			revokeSession(db, session.ID.Hex())
+			return utils.Unauthorized("Account not found or deactivated")
+		}
+
+		user.Password = "" // Don't return password
+
+		token, err := generateUserJWT(user.ID.Hex(), user.Phone, session.ID.Hex())
+		if err != nil {
+			return utils.Internal("Failed to generate token")
+		}
+
+		return c.JSON(models.UserAuthResponse{
+			Token:        token,
+			RefreshToken: refreshToken,
+			User:         user,
+		})
 	})
 
 	// Get User Profile (protected route)
-	auth.Get("/profile", func(c *fiber.Ctx) error {
+	auth.Get("/profile", middleware.UserJWTMiddleware(), func(c *fiber.Ctx) error {
 		userID := c.Locals("user_id").(string)
 
 		id, err := primitive.ObjectIDFromHex(userID)
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+			return utils.BadRequest("Invalid user ID")
 		}
 
 		collection := config.GetCollection(db, "users")
 		var user models.User
 		err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&user)
 		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+			return utils.NotFound("User not found")
 		}
 
 		user.Password = "" // Don't return password
@@ -164,26 +224,32 @@ func UserAuthRoutes(app fiber.Router, db *mongo.Client) {
 	})
 
 	// Update User Profile (protected route)
-	auth.Put("/profile", func(c *fiber.Ctx) error {
+	auth.Put("/profile", middleware.UserJWTMiddleware(), func(c *fiber.Ctx) error {
 		userID := c.Locals("user_id").(string)
 
 		id, err := primitive.ObjectIDFromHex(userID)
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+			return utils.BadRequest("Invalid user ID")
 		}
 
-		var updateData bson.M
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		// current_password confirms a password change; it is not a user field
+		body := c.Body()
+		currentPassword := ""
+		var raw map[string]json.RawMessage
+		if json.Unmarshal(body, &raw) == nil {
+			if value, ok := raw["current_password"]; ok {
+				json.Unmarshal(value, &currentPassword)
+				delete(raw, "current_password")
+				body, _ = json.Marshal(raw)
+			}
 		}
 
-		// Validate phone format if being updated
-		if phone, exists := updateData["phone"]; exists && phone != "" {
-			phoneStr := phone.(string)
-			if len(phoneStr) != 13 || phoneStr[:4] != "+998" {
-				return c.Status(400).JSON(fiber.Map{"error": "Phone must be in format +998XXXXXXXXX"})
-			}
+		updateData, errs := validation.Decode(body, models.User{}, true)
+		if len(errs) > 0 {
+			return validation.Failed(errs)
+		}
 
+		if phoneStr, exists := updateData["phone"].(string); exists {
 			// Check if phone already exists for another user
 			collection := config.GetCollection(db, "users")
 			var existingUser models.User
@@ -192,35 +258,54 @@ func UserAuthRoutes(app fiber.Router, db *mongo.Client) {
 				"_id":   bson.M{"$ne": id},
 			}).Decode(&existingUser)
 			if err == nil {
-				return c.Status(409).JSON(fiber.Map{"error": "Phone number already exists for another user"})
+				return utils.Conflict("Phone number already exists for another user")
 			}
+
+			// A new number has to be verified again
+			updateData["phone_verified"] = false
 		}
 
-		// Hash password if provided
-		if password, exists := updateData["password"]; exists && password != "" {
-			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password.(string)), bcrypt.DefaultCost)
+		// Changing the password here requires the current password as well, so
+		// a stolen access token alone cannot take over the account
+		password, changingPassword := updateData["password"].(string)
+		if changingPassword && password == "" {
+			delete(updateData, "password")
+			changingPassword = false
+		} else if changingPassword {
+			if ok, msg := utils.IsStrongPassword(password); !ok {
+				return validation.Failed(validation.Error("password", "weak_password", msg))
+			}
+			var current models.User
+			if err := config.GetCollection(db, "users").FindOne(context.TODO(), bson.M{"_id": id}).Decode(&current); err != nil {
+				return utils.NotFound("User not found")
+			}
+			if bcrypt.CompareHashAndPassword([]byte(current.Password), []byte(currentPassword)) != nil {
+				return utils.Unauthorized("Current password is incorrect")
+			}
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 			if err != nil {
-				return c.Status(500).JSON(fiber.Map{"error": "Failed to hash password"})
+				return utils.Internal("Failed to hash password")
 			}
 			updateData["password"] = string(hashedPassword)
 		}
 
-		// Don't allow users to change their active status
-		delete(updateData, "is_active")
-		delete(updateData, "_id")
-		delete(updateData, "last_login")
 		updateData["updated_at"] = time.Now()
 
 		collection := config.GetCollection(db, "users")
 		update := bson.M{"$set": updateData}
 
-		result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
+		err = auditedUpdate(c, db, "users", bson.M{"_id": id}, update)
+		if err == mongo.ErrNoDocuments {
+			return utils.NotFound("User not found")
+		}
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update profile"})
+			return utils.Internal("Failed to update profile")
 		}
 
-		if result.MatchedCount == 0 {
-			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+		// Sign out every other device that used the old password
+		if changingPassword {
+			sessionID, _ := c.Locals("session_id").(string)
+			revokeOtherSessions(db, "user", id, sessionID)
 		}
 
 		// Get updated user
@@ -231,25 +316,49 @@ func UserAuthRoutes(app fiber.Router, db *mongo.Client) {
 		return c.JSON(user)
 	})
 
-	// User Logout (optional - mainly for clearing tokens on frontend)
-	auth.Post("/logout", func(c *fiber.Ctx) error {
+	// User Logout - revoke the current session
+	auth.Post("/logout", middleware.UserJWTMiddleware(), func(c *fiber.Ctx) error {
+		sessionID, _ := c.Locals("session_id").(string)
+		if err := revokeSession(db, sessionID); err != nil {
+			return utils.Internal("Failed to log out")
+		}
+
 		return c.JSON(fiber.Map{"message": "Logged out successfully"})
 	})
+
+	// User Logout All - revoke every session of the current user
+	auth.Post("/logout-all", middleware.UserJWTMiddleware(), func(c *fiber.Ctx) error {
+		id, err := primitive.ObjectIDFromHex(c.Locals("user_id").(string))
+		if err != nil {
+			return utils.BadRequest("Invalid user ID")
+		}
+
+		revoked, err := revokeAllSessions(db, "user", id)
+		if err != nil {
+			return utils.Internal("Failed to log out")
+		}
+
+		return c.JSON(fiber.Map{
+			"message":          "Logged out from all devices",
+			"revoked_sessions": revoked,
+		})
+	})
 }
 
-// func generateUserJWT(userID, phone string) (string, error) {
-// 	jwtSecret := os.Getenv("JWT_SECRET")
-// 	if jwtSecret == "" {
-// 		jwtSecret = "your-super-secret-jwt-key-here"
-// 	}
-
-// 	claims := jwt.MapClaims{
-// 		"user_id": userID,
-// 		"phone":   phone,
-// 		"type":    "user",
-// 		"exp":     time.Now().Add(time.Hour * 24 * 30).Unix(), // 30 days
-// 	}
-
-// 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-// 	return token.SignedString([]byte(jwtSecret))
-// }
\ No newline at end of file
+func generateUserJWT(userID, phone, sessionID string) (string, error) {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		jwtSecret = "your-super-secret-jwt-key-here"
+	}
+
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"phone":   phone,
+		"sid":     sessionID,
+		"type":    "user",
+		"exp":     time.Now().Add(accessTokenTTL).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(jwtSecret))
+}
@@ -0,0 +1,275 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"time"
+
+	"fiber-ecommerce/config"
+	"fiber-ecommerce/middleware"
+	"fiber-ecommerce/models"
+	"fiber-ecommerce/notify"
+	"fiber-ecommerce/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userDetailOrderLimit caps the recent orders embedded in GET /users/:id.
+const userDetailOrderLimit = 20
+
+// findUser loads the user addressed by the :id route parameter.
+func findUser(c *fiber.Ctx, db *mongo.Client) (models.User, error) {
+	var user models.User
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return user, err
+	}
+	err = config.GetCollection(db, "users").FindOne(context.TODO(), bson.M{"_id": id}).Decode(&user)
+	return user, err
+}
+
+// setUserActive activates or deactivates a user. Deactivation also signs the
+// user out of every device.
+func setUserActive(db *mongo.Client, c *fiber.Ctx, active bool) error {
+	user, err := findUser(c, db)
+	if err != nil {
+		return utils.NotFound("User not found")
+	}
+
+	err = auditedUpdate(c, db, "users", bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+		"is_active":  active,
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		return utils.Internal("Failed to update user")
+	}
+
+	if !active {
+		revokeAllSessions(db, "user", user.ID)
+	}
+
+	user.IsActive = active
+	user.Password = "" // Don't return password
+	return c.JSON(user)
+}
+
+// UserManagementRoutes lets admins browse and manage customer accounts.
+func UserManagementRoutes(app fiber.Router, db *mongo.Client, notifier notify.Notifier) {
+	users := app.Group("/users")
+
+	// List users with optional search, status and registration date filters
+	users.Get("/", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
+		page, limit, skip := utils.ParsePaginationParams(c)
+
+		filter := utils.GenerateSearchFilter(regexp.QuoteMeta(c.Query("search")), []string{"name", "email", "phone"})
+		switch c.Query("is_active") {
+		case "true":
+			filter["is_active"] = true
+		case "false":
+			filter["is_active"] = false
+		}
+		switch c.Query("phone_verified") {
+		case "true":
+			filter["phone_verified"] = true
+		case "false":
+			filter["phone_verified"] = bson.M{"$ne": true}
+		}
+
+		startDate, endDate, err := utils.ParseDateRange(c.Query("start_date"), c.Query("end_date"))
+		if err != nil {
+			return utils.BadRequest(err.Error())
+		}
+		if !startDate.IsZero() || !endDate.IsZero() {
+			createdAt := bson.M{}
+			if !startDate.IsZero() {
+				createdAt["$gte"] = startDate
+			}
+			if !endDate.IsZero() {
+				createdAt["$lt"] = endDate
+			}
+			filter["created_at"] = createdAt
+		}
+
+		collection := config.GetCollection(db, "users")
+		opts := options.Find().
+			SetSkip(int64(skip)).
+			SetLimit(int64(limit)).
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetProjection(bson.M{"password": 0})
+
+		cursor, err := collection.Find(context.TODO(), filter, opts)
+		if err != nil {
+			return utils.Internal("Failed to fetch users")
+		}
+		defer cursor.Close(context.TODO())
+
+		list := []models.User{}
+		if err = cursor.All(context.TODO(), &list); err != nil {
+			return utils.Internal("Failed to decode users")
+		}
+
+		total, _ := collection.CountDocuments(context.TODO(), filter)
+
+		return c.JSON(utils.PaginationResponse(list, total, page, limit))
+	})
+
+	// Get a user with their most recent orders
+	users.Get("/:id", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
+		user, err := findUser(c, db)
+		if err != nil {
+			return utils.NotFound("User not found")
+		}
+		user.Password = "" // Don't return password
+
+		ordersCollection := config.GetCollection(db, "orders")
+		orderFilter := notTrashed(bson.M{"$or": bson.A{bson.M{"user_id": user.ID}, bson.M{"phone": user.Phone}}})
+		opts := options.Find().SetLimit(userDetailOrderLimit).SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+		cursor, err := ordersCollection.Find(context.TODO(), orderFilter, opts)
+		if err != nil {
+			return utils.Internal("Failed to fetch orders")
+		}
+		defer cursor.Close(context.TODO())
+
+		orders := []models.Order{}
+		if err = cursor.All(context.TODO(), &orders); err != nil {
+			return utils.Internal("Failed to decode orders")
+		}
+
+		orderCount, _ := ordersCollection.CountDocuments(context.TODO(), orderFilter)
+
+		return c.JSON(fiber.Map{
+			"user":        user,
+			"orders":      orders,
+			"order_count": orderCount,
+		})
+	})
+
+	// Deactivate - block login and revoke all sessions
+	users.Post("/:id/deactivate", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
+		return setUserActive(db, c, false)
+	})
+
+	// Activate - allow a deactivated user to log in again
+	users.Post("/:id/activate", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
+		return setUserActive(db, c, true)
+	})
+
+	// Force password reset - invalidate the current password and send a reset token
+	users.Post("/:id/force-password-reset", middleware.AdminJWTMiddleware(salesRoles...), func(c *fiber.Ctx) error {
+		user, err := findUser(c, db)
+		if err != nil {
+			return utils.NotFound("User not found")
+		}
+
+		// Replace the password with a random one nobody knows
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return utils.Internal("Failed to generate password")
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(buf)), bcrypt.DefaultCost)
+		if err != nil {
+			return utils.Internal("Failed to hash password")
+		}
+
+		err = auditedUpdate(c, db, "users", bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+			"password":   string(hashedPassword),
+			"updated_at": time.Now(),
+		}})
+		if err != nil {
+			return utils.Internal("Failed to update user")
+		}
+
+		revoked, _ := revokeAllSessions(db, "user", user.ID)
+
+		// Drop any pending token so the cooldown does not swallow this one
+		config.GetCollection(db, "password_resets").DeleteMany(context.TODO(), bson.M{
+			"subject_type": "user",
+			"subject_id":   user.ID,
+			"used_at":      nil,
+		})
+
+		to := notify.Recipient{Phone: user.Phone, Email: user.Email}
+		if err := issuePasswordReset(db, notifier, "user", user.ID, to); err != nil {
+			log.Printf("Failed to send forced password reset to user %s: %v", user.ID.Hex(), err)
+			return utils.UpstreamFailed("Password invalidated but reset instructions could not be sent").
+				With("revoked_sessions", revoked)
+		}
+
+		return c.JSON(fiber.Map{
+			"message":          "Password reset required. Reset instructions have been sent to the user.",
+			"revoked_sessions": revoked,
+		})
+	})
+
+	// Delete a user and anonymize their orders
+	users.Delete("/:id", middleware.AdminJWTMiddleware(superAdminRoles...), func(c *fiber.Ctx) error {
+		user, err := findUser(c, db)
+		if err != nil {
+			return utils.NotFound("User not found")
+		}
+
+		// Orders are kept for reporting but no longer point to the person
+		now := time.Now()
+		ordersFilter := bson.M{"$or": bson.A{bson.M{"user_id": user.ID}, bson.M{"phone": user.Phone}}}
+		orderIDs, err := findIDs(context.TODO(), db, "orders", ordersFilter)
+		if err != nil {
+			return utils.Internal("Failed to anonymize orders")
+		}
+		ordersResult, err := config.GetCollection(db, "orders").UpdateMany(context.TODO(),
+			bson.M{"_id": bson.M{"$in": orderIDs}},
+			bson.M{
+				"$set": bson.M{
+					"phone":         "",
+					"anonymized_at": now,
+					"updated_at":    now,
+				},
+				"$unset": bson.M{"user_id": ""},
+			},
+		)
+		if err != nil {
+			return utils.Internal("Failed to anonymize orders")
+		}
+
+		if _, err := config.GetCollection(db, "users").DeleteOne(context.TODO(), bson.M{"_id": user.ID}); err != nil {
+			return utils.Internal("Failed to delete user")
+		}
+
+		// The audit trail must not keep the personal data the delete removes,
+		// neither in new events nor in the ones recorded before
+		if err := redactAuditHistory(context.TODO(), db, "users", []primitive.ObjectID{user.ID}, []string{"name", "phone", "email"}, user.ID.Hex()); err != nil {
+			return utils.Internal("Failed to anonymize the audit log")
+		}
+		if err := redactAuditHistory(context.TODO(), db, "orders", orderIDs, []string{"phone"}, ""); err != nil {
+			return utils.Internal("Failed to anonymize the audit log")
+		}
+		// Status changes and stock movements the user caused name them by phone
+		if err := forgetUserActor(context.TODO(), db, user.ID.Hex(), user.Phone); err != nil {
+			return utils.Internal("Failed to anonymize order histories")
+		}
+		if err := forgetLoginHistory(context.TODO(), db, "user", user.ID, user.Phone, user.Email); err != nil {
+			return utils.Internal("Failed to delete the login history")
+		}
+		for _, orderID := range orderIDs {
+			recordAudit(c, db, models.AuditActionUpdate, "orders", orderID, nil, bson.M{"anonymized_at": now})
+		}
+		recordAudit(c, db, models.AuditActionDelete, "users", user.ID, nil, nil)
+
+		revokeAllSessions(db, "user", user.ID)
+		config.GetCollection(db, "password_resets").DeleteMany(context.TODO(), bson.M{"subject_type": "user", "subject_id": user.ID})
+		config.GetCollection(db, "phone_otps").DeleteMany(context.TODO(), bson.M{"phone": user.Phone})
+
+		return c.JSON(fiber.Map{
+			"message":           "User deleted successfully",
+			"anonymized_orders": ordersResult.ModifiedCount,
+		})
+	})
+}
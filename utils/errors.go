@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Error codes sent in the "code" field of every error response. Clients
+// should branch on these rather than on the message text.
+const (
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	CodeConflict         = "CONFLICT"
+	CodePayloadTooLarge  = "PAYLOAD_TOO_LARGE"
+	CodeTooManyRequests  = "TOO_MANY_REQUESTS"
+	CodeInternal         = "INTERNAL_ERROR"
+	CodeUpstreamFailed   = "UPSTREAM_FAILED"
+)
+
+// AppError is an error a handler returns to answer with a given status and
+// code. Details are rendered next to the code and message.
+type AppError struct {
+	Status  int
+	Code    string
+	Message string
+	Details fiber.Map
+}
+
+func (e *AppError) Error() string {
+	return e.Message
+}
+
+// With adds a detail field to the error response.
+func (e *AppError) With(key string, value interface{}) *AppError {
+	if e.Details == nil {
+		e.Details = fiber.Map{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// NewError creates an application error with an explicit status and code.
+func NewError(status int, code, message string) *AppError {
+	return &AppError{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *AppError {
+	return NewError(fiber.StatusBadRequest, CodeBadRequest, message)
+}
+
+func ValidationFailed(message string) *AppError {
+	return NewError(fiber.StatusUnprocessableEntity, CodeValidationFailed, message)
+}
+
+func Unauthorized(message string) *AppError {
+	return NewError(fiber.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *AppError {
+	return NewError(fiber.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *AppError {
+	return NewError(fiber.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *AppError {
+	return NewError(fiber.StatusConflict, CodeConflict, message)
+}
+
+func PayloadTooLarge(message string) *AppError {
+	return NewError(fiber.StatusRequestEntityTooLarge, CodePayloadTooLarge, message)
+}
+
+func TooManyRequests(message string) *AppError {
+	return NewError(fiber.StatusTooManyRequests, CodeTooManyRequests, message)
+}
+
+func Internal(message string) *AppError {
+	return NewError(fiber.StatusInternalServerError, CodeInternal, message)
+}
+
+// UpstreamFailed reports a failure of an external service such as the SMS gateway.
+func UpstreamFailed(message string) *AppError {
+	return NewError(fiber.StatusBadGateway, CodeUpstreamFailed, message)
+}
+
+// codeForStatus picks the code of errors raised by Fiber itself (unknown
+// routes, body limits, ...).
+func codeForStatus(status int) string {
+	switch status {
+	case fiber.StatusBadRequest:
+		return CodeBadRequest
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound:
+		return CodeNotFound
+	case fiber.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case fiber.StatusConflict:
+		return CodeConflict
+	case fiber.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case fiber.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case fiber.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case fiber.StatusBadGateway:
+		return CodeUpstreamFailed
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// ErrorResponse builds the error envelope shared by every route.
+func ErrorResponse(err *AppError, requestID string) fiber.Map {
+	body := fiber.Map{}
+	for key, value := range err.Details {
+		body[key] = value
+	}
+	body["code"] = err.Code
+	body["message"] = err.Message
+	body["status"] = err.Status
+	body["request_id"] = requestID
+	body["timestamp"] = Now()
+	return fiber.Map{"error": body}
+}
+
+// ErrorHandler renders errors returned by handlers and middleware. Errors
+// that are not an *AppError or *fiber.Error are logged and hidden behind a
+// generic internal error.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	requestID := c.GetRespHeader(fiber.HeaderXRequestID)
+
+	var appErr *AppError
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &appErr):
+	case errors.As(err, &fiberErr):
+		appErr = NewError(fiberErr.Code, codeForStatus(fiberErr.Code), fiberErr.Message)
+	default:
+		log.Printf("Unhandled error in %s %s (request %s): %v", c.Method(), c.Path(), requestID, err)
+		appErr = Internal("Internal server error")
+	}
+
+	return c.Status(appErr.Status).JSON(ErrorResponse(appErr, requestID))
+}
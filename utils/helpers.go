@@ -66,7 +66,11 @@ func IsValidObjectID(id string) bool {
 	return err == nil
 }
 
-// Parse pagination parameters
+// MaxPageSize caps the limit of list endpoints.
+const MaxPageSize = 100
+
+// Parse pagination parameters. Invalid values fall back to the defaults and
+// limits above MaxPageSize are capped.
 func ParsePaginationParams(c *fiber.Ctx) (int, int, int) {
 	page := 1
 	limit := 10
@@ -78,8 +82,8 @@ func ParsePaginationParams(c *fiber.Ctx) (int, int, int) {
 	}
 
 	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = min(l, MaxPageSize)
 		}
 	}
 
@@ -149,22 +153,6 @@ func PaginationResponse(data interface{}, total int64, page, limit int) fiber.Ma
 	}
 }
 
-// Error response helper
-func ErrorResponse(message string, code ...int) fiber.Map {
-	statusCode := 400
-	if len(code) > 0 {
-		statusCode = code[0]
-	}
-
-	return fiber.Map{
-		"error": fiber.Map{
-			"message":   message,
-			"code":      statusCode,
-			"timestamp": Now(),
-		},
-	}
-}
-
 // Success response helper
 func SuccessResponse(data interface{}, message ...string) fiber.Map {
 	msg := "Operation successful"
@@ -0,0 +1,272 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Parse decodes a JSON request body into dst, a pointer to a struct, and
+// checks every field. Unknown fields and values of the wrong type are
+// rejected.
+func Parse(body []byte, dst interface{}) []FieldError {
+	value := reflect.ValueOf(dst).Elem()
+	_, errs := parse(body, value, false)
+	return errs
+}
+
+// Decode checks a JSON request body against a model and returns the document
+// to store, keyed by bson field names. Creates get every field of the model;
+// updates (partial) get only the fields present in the body, and only those
+// are checked.
+func Decode(body []byte, model interface{}, partial bool) (bson.M, []FieldError) {
+	s := schemaOf(reflect.TypeOf(model))
+	value := reflect.New(s.Type).Elem()
+	present, errs := parse(body, value, partial)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if partial {
+		set := bson.M{}
+		for name := range present {
+			fd := s.Fields[s.ByName[name]]
+			set[fd.BSON] = value.Field(fd.Index).Interface()
+		}
+		return set, nil
+	}
+
+	doc := bson.M{}
+	raw, err := bson.Marshal(value.Interface())
+	if err == nil {
+		err = bson.Unmarshal(raw, &doc)
+	}
+	if err != nil {
+		return nil, Error("", "invalid_body", "Request body could not be stored")
+	}
+	delete(doc, "_id")
+	return doc, nil
+}
+
+// parse decodes body into value and checks it, returning the JSON fields the
+// body set.
+func parse(body []byte, value reflect.Value, partial bool) (map[string]bool, []FieldError) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil || raw == nil {
+		return nil, Error("", "invalid_body", "Request body must be a JSON object")
+	}
+
+	present, failed, errs := decodeObject(raw, value, "", partial)
+
+	only := present
+	if !partial {
+		only = nil
+	}
+	for _, e := range checkStruct(value, "", only) {
+		top, _, _ := strings.Cut(strings.SplitN(e.Field, "[", 2)[0], ".")
+		if !failed[top] {
+			errs = append(errs, e)
+		}
+	}
+
+	sortByField(errs)
+	return present, errs
+}
+
+// decodeObject decodes the fields of a JSON object into value, a struct,
+// returning the fields set and the fields that failed. Unknown fields are
+// rejected, readonly fields are skipped and, unless partial, missing required
+// fields are reported. Errors are named prefix + the JSON field name.
+func decodeObject(raw map[string]json.RawMessage, value reflect.Value, prefix string, partial bool) (map[string]bool, map[string]bool, []FieldError) {
+	s := schemaOf(value.Type())
+	present := map[string]bool{}
+	failed := map[string]bool{}
+	errs := []FieldError{}
+	fail := func(name, code, message string) {
+		failed[name] = true
+		errs = append(errs, FieldError{Field: prefix + name, Code: code, Message: message})
+	}
+
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		index, known := s.ByName[name]
+		if !known {
+			if !serverFields[name] {
+				fail(name, "unknown_field", "Unknown field")
+			}
+			continue
+		}
+		fd := s.Fields[index]
+		if fd.ReadOnly || serverFields[name] {
+			continue
+		}
+
+		v, fieldErrs := decodeField(fd.Type, raw[name], prefix+name, fd.Coerce)
+		if len(fieldErrs) > 0 {
+			failed[name] = true
+			errs = append(errs, fieldErrs...)
+			continue
+		}
+		if v.Kind() == reflect.String && hasRule(fd, "currency") {
+			v.SetString(strings.ToUpper(strings.TrimSpace(v.String())))
+		}
+		value.Field(fd.Index).Set(v)
+		present[name] = true
+	}
+
+	if !partial {
+		for _, fd := range s.Fields {
+			if fd.Required && !fd.ReadOnly && !present[fd.JSON] && !failed[fd.JSON] {
+				fail(fd.JSON, "required", "Field is required")
+			}
+		}
+	}
+	return present, failed, errs
+}
+
+// hasRule reports whether a field declares the named rule.
+func hasRule(fd field, name string) bool {
+	for _, r := range fd.Rules {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeField decodes one JSON value at path into a value of type t, coercing
+// scalars when coerce is set. Objects and lists of objects are decoded field
+// by field with decodeObject, so their errors are named like
+// products[0].quantity.
+func decodeField(t reflect.Type, raw json.RawMessage, path string, coerce bool) (reflect.Value, []FieldError) {
+	v := reflect.New(t).Elem()
+	if strings.TrimSpace(string(raw)) == "null" {
+		return v, nil
+	}
+
+	switch {
+	case t.Kind() == reflect.Ptr:
+		elem, errs := decodeField(t.Elem(), raw, path, coerce)
+		if len(errs) > 0 {
+			return v, errs
+		}
+		v.Set(reflect.New(t.Elem()))
+		v.Elem().Set(elem)
+		return v, nil
+
+	case isNested(t):
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(raw, &fields) != nil {
+			return v, Error(path, "invalid_type", typeMessage(t))
+		}
+		_, _, errs := decodeObject(fields, v, path+".", false)
+		return v, errs
+
+	case t.Kind() == reflect.Slice && isNested(t.Elem()):
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			return v, Error(path, "invalid_type", typeMessage(t))
+		}
+		v.Set(reflect.MakeSlice(t, len(items), len(items)))
+		var errs []FieldError
+		for i, item := range items {
+			elem, itemErrs := decodeField(t.Elem(), item, fmt.Sprintf("%s[%d]", path, i), false)
+			errs = append(errs, itemErrs...)
+			v.Index(i).Set(elem)
+		}
+		return v, errs
+	}
+
+	p := reflect.New(t)
+	err := json.Unmarshal(raw, p.Interface())
+	if err != nil && coerce {
+		if coerced, ok := coerceJSON(raw, t); ok {
+			p = reflect.New(t)
+			err = json.Unmarshal(coerced, p.Interface())
+		}
+	}
+	if err != nil {
+		return v, Error(path, "invalid_type", typeMessage(t))
+	}
+	return p.Elem(), nil
+}
+
+// coerceJSON rewrites a scalar JSON value to the kind of t: numbers and
+// booleans sent for a string are quoted, and strings sent for a number or
+// boolean are unquoted.
+func coerceJSON(raw json.RawMessage, t reflect.Type) (json.RawMessage, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, false
+	}
+
+	if t.Kind() == reflect.String {
+		if strings.ContainsAny(trimmed[:1], `"{[`) {
+			return nil, false
+		}
+		quoted, _ := json.Marshal(trimmed)
+		return quoted, true
+	}
+
+	numeric := false
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		numeric = true
+	case reflect.Struct:
+		// Value types such as models.FlexFloat64 decode from a number
+		numeric = t != timeType && reflect.PtrTo(t).Implements(unmarshalerType)
+	}
+	var s string
+	if !numeric || json.Unmarshal(raw, &s) != nil || strings.TrimSpace(s) == "" {
+		return nil, false
+	}
+	return json.RawMessage(strings.TrimSpace(s)), true
+}
+
+// typeMessage describes the JSON type a field expects.
+func typeMessage(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case objectIDType:
+		return "Must be a valid ObjectID"
+	case timeType:
+		return "Must be an RFC3339 date"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "Must be a string"
+	case reflect.Bool:
+		return "Must be a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "Must be an integer"
+	case reflect.Float32, reflect.Float64:
+		return "Must be a number"
+	case reflect.Slice, reflect.Array:
+		return "Must be a list"
+	case reflect.Struct:
+		if reflect.PtrTo(t).Implements(unmarshalerType) {
+			return "Must be a number"
+		}
+		return "Must be an object"
+	case reflect.Map:
+		return "Must be an object"
+	}
+	return "Has an invalid type"
+}
@@ -0,0 +1,315 @@
+// Package validation checks request bodies against rules declared in the
+// validate struct tag of the models and request types:
+//
+//	required      present and not empty
+//	min=N, max=N  length of strings and lists, value of numbers
+//	enum=a|b|c    one of the listed values
+//	email         an email address
+//	uz-phone      a phone number in the +998XXXXXXXXX format
+//	objectid      a hex ObjectID string
+//	currency      one of the supported currency codes (upper-cased on decode)
+//	rfc3339       an RFC3339 date string
+//	coerce        accept numbers sent as strings and the other way round
+//	readonly      set by the server; ignored in request bodies
+//
+// Every failure is reported as a FieldError; Failed wraps them in a 422
+// VALIDATION_FAILED error.
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"fiber-ecommerce/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Currencies lists the accepted currency codes.
+var Currencies = []string{"UZS", "USD", "EUR"}
+
+// FieldError describes why one field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error builds a single-entry error list for checks handlers make themselves.
+func Error(field, code, message string) []FieldError {
+	return []FieldError{{Field: field, Code: code, Message: message}}
+}
+
+// Failed returns the VALIDATION_FAILED error shared by every handler, listing
+// errs under "fields".
+func Failed(errs []FieldError) error {
+	return utils.ValidationFailed("Request validation failed").With("fields", errs)
+}
+
+// rule is one entry of a validate tag, e.g. min=3.
+type rule struct {
+	Name  string
+	Param string
+}
+
+// field is one JSON field of a struct type.
+type field struct {
+	JSON     string
+	BSON     string
+	Index    int
+	Type     reflect.Type
+	Rules    []rule
+	Required bool
+	Coerce   bool
+	ReadOnly bool
+}
+
+// schema describes the fields of a struct type in declaration order.
+type schema struct {
+	Type   reflect.Type
+	Fields []field
+	ByName map[string]int
+}
+
+var schemas sync.Map
+
+// serverFields are managed by the server and ignored in request bodies.
+var serverFields = map[string]bool{
+	"id":         true,
+	"_id":        true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// schemaOf reads (and caches) the json, bson and validate tags of a struct type.
+func schemaOf(t reflect.Type) *schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if cached, ok := schemas.Load(t); ok {
+		return cached.(*schema)
+	}
+
+	s := &schema{Type: t, ByName: map[string]int{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := tagName(f.Tag.Get("json"))
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		bsonName := tagName(f.Tag.Get("bson"))
+		if bsonName == "" {
+			bsonName = strings.ToLower(f.Name)
+		}
+
+		fd := field{JSON: name, BSON: bsonName, Index: i, Type: f.Type}
+		for _, part := range strings.Split(f.Tag.Get("validate"), ",") {
+			ruleName, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch ruleName {
+			case "":
+			case "required":
+				fd.Required = true
+			case "coerce":
+				fd.Coerce = true
+			case "readonly":
+				fd.ReadOnly = true
+			default:
+				fd.Rules = append(fd.Rules, rule{Name: ruleName, Param: param})
+			}
+		}
+		s.ByName[name] = len(s.Fields)
+		s.Fields = append(s.Fields, fd)
+	}
+
+	schemas.Store(t, s)
+	return s
+}
+
+// tagName returns the name part of a struct tag value.
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+// Struct checks every field of a struct (or pointer to one), including
+// nested structs and lists of structs.
+func Struct(v interface{}) []FieldError {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	return checkStruct(value, "", nil)
+}
+
+// checkStruct checks the fields of a struct value. When only is set, fields
+// missing from it are skipped (partial updates).
+func checkStruct(value reflect.Value, prefix string, only map[string]bool) []FieldError {
+	s := schemaOf(value.Type())
+	errs := []FieldError{}
+	for _, fd := range s.Fields {
+		if only != nil && !only[fd.JSON] {
+			continue
+		}
+		errs = append(errs, checkField(value.Field(fd.Index), fd, prefix+fd.JSON)...)
+	}
+	return errs
+}
+
+// checkField applies the rules of one field and descends into nested structs.
+func checkField(value reflect.Value, fd field, path string) []FieldError {
+	if isEmpty(value) {
+		if fd.Required {
+			return Error(path, "required", "Field is required")
+		}
+		return nil
+	}
+	for _, r := range fd.Rules {
+		if message, ok := checkRule(value, r); !ok {
+			return Error(path, r.Name, message)
+		}
+	}
+
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	switch {
+	case isNested(value.Type()):
+		return checkStruct(value, path+".", nil)
+	case value.Kind() == reflect.Slice && isNested(value.Type().Elem()):
+		var errs []FieldError
+		for i := 0; i < value.Len(); i++ {
+			errs = append(errs, checkStruct(value.Index(i), fmt.Sprintf("%s[%d].", path, i), nil)...)
+		}
+		return errs
+	}
+	return nil
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	objectIDType    = reflect.TypeOf(primitive.ObjectID{})
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// isNested reports whether t is a struct whose own fields carry rules, as
+// opposed to a value type such as time.Time or models.FlexFloat64.
+func isNested(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(unmarshalerType)
+}
+
+// isEmpty reports whether a value counts as missing for the required rule.
+// Numbers and booleans are never empty: their presence is checked on decode.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil() || isEmpty(v.Elem())
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return false
+	}
+	return v.IsZero()
+}
+
+// checkRule applies one rule to a non-empty value.
+func checkRule(v reflect.Value, r rule) (string, bool) {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	str := ""
+	if v.Kind() == reflect.String {
+		str = strings.TrimSpace(v.String())
+	}
+
+	switch r.Name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(r.Param, 64)
+		if err != nil {
+			return "", true
+		}
+		size, unit, ok := measure(v)
+		if !ok {
+			return "", true
+		}
+		if r.Name == "min" && size < limit {
+			return fmt.Sprintf("Must be at least %s%s", r.Param, unit), false
+		}
+		if r.Name == "max" && size > limit {
+			return fmt.Sprintf("Must be at most %s%s", r.Param, unit), false
+		}
+	case "enum":
+		allowed := strings.Split(r.Param, "|")
+		for _, option := range allowed {
+			if str == option {
+				return "", true
+			}
+		}
+		return "Must be one of " + strings.Join(allowed, ", "), false
+	case "email":
+		if !utils.IsValidEmail(str) {
+			return "Must be a valid email address", false
+		}
+	case "uz-phone":
+		if !utils.IsValidUzbekPhone(str) {
+			return "Phone must be in format +998XXXXXXXXX", false
+		}
+	case "objectid":
+		if v.Type() != objectIDType && !utils.IsValidObjectID(str) {
+			return "Must be a valid ObjectID", false
+		}
+	case "currency":
+		for _, code := range Currencies {
+			if str == code {
+				return "", true
+			}
+		}
+		return "Must be one of " + strings.Join(Currencies, ", "), false
+	case "rfc3339":
+		if v.Type() != timeType {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return "Must be an RFC3339 date", false
+			}
+		}
+	}
+	return "", true
+}
+
+// measure returns what min and max compare: the length of strings and lists,
+// or the value of numbers.
+func measure(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Map:
+		return float64(v.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+	if number, ok := v.Interface().(interface{ Float64() float64 }); ok {
+		return number.Float64(), "", true
+	}
+	return 0, "", false
+}
+
+// sortByField orders errors by field name so responses are stable.
+func sortByField(errs []FieldError) {
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+}
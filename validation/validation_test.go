@@ -0,0 +1,168 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type sampleLine struct {
+	SKU   string  `json:"sku" validate:"required"`
+	Qty   int     `json:"qty" validate:"coerce,max=9"`
+	Price float64 `json:"price" validate:"readonly"`
+}
+
+type sample struct {
+	Name     string       `json:"name" bson:"name" validate:"required,min=2,max=5"`
+	Status   string       `json:"status" bson:"status" validate:"enum=new|done"`
+	Email    string       `json:"email" bson:"email" validate:"email"`
+	Phone    string       `json:"phone" bson:"phone" validate:"uz-phone"`
+	Ref      string       `json:"ref" bson:"ref" validate:"objectid"`
+	Currency string       `json:"currency" bson:"currency" validate:"currency"`
+	Date     string       `json:"date" bson:"date" validate:"rfc3339"`
+	Count    *int         `json:"count" bson:"count" validate:"min=1,max=10"`
+	Code     string       `json:"code" bson:"code" validate:"coerce"`
+	Qty      int          `json:"qty" bson:"qty" validate:"coerce"`
+	Tags     []string     `json:"tags" bson:"tags" validate:"max=2"`
+	Total    float64      `json:"total" bson:"total" validate:"readonly"`
+	Lines    []sampleLine `json:"lines" bson:"lines"`
+}
+
+// failures renders errors as field:code pairs.
+func failures(errs []FieldError) []string {
+	out := []string{}
+	for _, e := range errs {
+		out = append(out, e.Field+":"+e.Code)
+	}
+	return out
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"valid", `{"name":"Ann","status":"new","email":"a@b.uz","phone":"+998901234567","ref":"507f1f77bcf86cd799439011","currency":"usd","date":"2024-05-01T10:00:00Z","count":3,"tags":["a"],"lines":[{"sku":"x"}]}`, []string{}},
+		{"missing required", `{}`, []string{"name:required"}},
+		{"blank required", `{"name":"  "}`, []string{"name:required"}},
+		{"string too short", `{"name":"A"}`, []string{"name:min"}},
+		{"string too long", `{"name":"Annabel"}`, []string{"name:max"}},
+		{"max counts runes", `{"name":"Ўзбек"}`, []string{}},
+		{"number below min", `{"name":"Ann","count":0}`, []string{"count:min"}},
+		{"number above max", `{"name":"Ann","count":11}`, []string{"count:max"}},
+		{"list too long", `{"name":"Ann","tags":["a","b","c"]}`, []string{"tags:max"}},
+		{"enum", `{"name":"Ann","status":"old"}`, []string{"status:enum"}},
+		{"email", `{"name":"Ann","email":"ann@"}`, []string{"email:email"}},
+		{"uz-phone", `{"name":"Ann","phone":"901234567"}`, []string{"phone:uz-phone"}},
+		{"objectid", `{"name":"Ann","ref":"123"}`, []string{"ref:objectid"}},
+		{"currency", `{"name":"Ann","currency":"RUB"}`, []string{"currency:currency"}},
+		{"rfc3339", `{"name":"Ann","date":"2024-05-01"}`, []string{"date:rfc3339"}},
+		{"coerce number to string", `{"name":"Ann","code":42}`, []string{}},
+		{"coerce string to number", `{"name":"Ann","qty":"7"}`, []string{}},
+		{"coerce rejects text for a number", `{"name":"Ann","qty":"seven"}`, []string{"qty:invalid_type"}},
+		{"wrong type", `{"name":5}`, []string{"name:invalid_type"}},
+		{"unknown field", `{"name":"Ann","colour":"red"}`, []string{"colour:unknown_field"}},
+		{"server fields are ignored", `{"name":"Ann","id":"x","created_at":"y"}`, []string{}},
+		{"readonly is ignored", `{"name":"Ann","total":"not a number"}`, []string{}},
+		{"nested rules", `{"name":"Ann","lines":[{"sku":"a"},{}]}`, []string{"lines[1].sku:required"}},
+		{"nested rule on a value", `{"name":"Ann","lines":[{"sku":"a","qty":10}]}`, []string{"lines[0].qty:max"}},
+		{"nested unknown field", `{"name":"Ann","lines":[{"sku":"a","colour":"red"}]}`, []string{"lines[0].colour:unknown_field"}},
+		{"nested wrong type", `{"name":"Ann","lines":[{"sku":"a","qty":"many"}]}`, []string{"lines[0].qty:invalid_type"}},
+		{"nested coerce", `{"name":"Ann","lines":[{"sku":"a","qty":"2"}]}`, []string{}},
+		{"nested readonly is ignored", `{"name":"Ann","lines":[{"sku":"a","price":"free"}]}`, []string{}},
+		{"nested list of the wrong type", `{"name":"Ann","lines":{"sku":"a"}}`, []string{"lines:invalid_type"}},
+		{"nested item of the wrong type", `{"name":"Ann","lines":["a"]}`, []string{"lines[0]:invalid_type"}},
+		{"not an object", `[1,2]`, []string{":invalid_body"}},
+		{"errors sorted by field", `{"status":"x","email":"y"}`, []string{"email:email", "name:required", "status:enum"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst sample
+			got := failures(Parse([]byte(tt.body), &dst))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%s) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		partial bool
+		want    bson.M
+		errs    []string
+	}{
+		{
+			name:    "partial keeps only the fields sent",
+			body:    `{"status":"done","currency":" eur "}`,
+			partial: true,
+			want:    bson.M{"status": "done", "currency": "EUR"},
+		},
+		{
+			name:    "partial skips required fields not sent",
+			body:    `{"qty":"3"}`,
+			partial: true,
+			want:    bson.M{"qty": 3},
+		},
+		{
+			name:    "partial checks the fields sent",
+			body:    `{"name":"A"}`,
+			partial: true,
+			errs:    []string{"name:min"},
+		},
+		{
+			name:    "partial ignores readonly fields",
+			body:    `{"total":10}`,
+			partial: true,
+			want:    bson.M{},
+		},
+		{
+			name:    "partial drops readonly fields of lines",
+			body:    `{"lines":[{"sku":"a","qty":"2","price":5}]}`,
+			partial: true,
+			want:    bson.M{"lines": []sampleLine{{SKU: "a", Qty: 2}}},
+		},
+		{
+			name: "create checks required fields",
+			body: `{"status":"new"}`,
+			errs: []string{"name:required"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := Decode([]byte(tt.body), sample{}, tt.partial)
+			if tt.errs != nil {
+				if f := failures(errs); !reflect.DeepEqual(f, tt.errs) {
+					t.Fatalf("errors = %v, want %v", f, tt.errs)
+				}
+				return
+			}
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors %v", failures(errs))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode(%s) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCreate(t *testing.T) {
+	doc, errs := Decode([]byte(`{"name":"Ann","currency":"usd","total":99}`), sample{}, false)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors %v", failures(errs))
+	}
+	if doc["name"] != "Ann" || doc["currency"] != "USD" {
+		t.Errorf("doc = %v", doc)
+	}
+	if doc["total"] != 0.0 {
+		t.Errorf("readonly total = %v, want the zero value", doc["total"])
+	}
+	if _, ok := doc["_id"]; ok {
+		t.Error("doc carries _id")
+	}
+}